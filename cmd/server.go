@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
@@ -11,8 +12,15 @@ import (
 	"time"
 
 	"github.com/juancollazo-ch/dropi-order-status-service/internal/api"
+	"github.com/juancollazo-ch/dropi-order-status-service/internal/auth"
+	"github.com/juancollazo-ch/dropi-order-status-service/internal/compare"
 	"github.com/juancollazo-ch/dropi-order-status-service/internal/handlers"
+	"github.com/juancollazo-ch/dropi-order-status-service/internal/models"
+	"github.com/juancollazo-ch/dropi-order-status-service/internal/scheduler"
 	"github.com/juancollazo-ch/dropi-order-status-service/internal/service"
+	"github.com/juancollazo-ch/dropi-order-status-service/internal/sla"
+	"github.com/juancollazo-ch/dropi-order-status-service/internal/state"
+	"github.com/juancollazo-ch/dropi-order-status-service/internal/tenant"
 	"github.com/juancollazo-ch/dropi-order-status-service/internal/webhook"
 	"github.com/juancollazo-ch/dropi-order-status-service/internal/worker"
 	"go.uber.org/zap"
@@ -60,26 +68,165 @@ func main() {
 
 	sender := webhook.NewSender()
 
-	workerPool := worker.NewWorkerPool(sender, 50) // 50 workers concurrentes
+	// Tamaño y autoscaling del pool: WORKER_POOL_SIZE (default 50), WORKER_POOL_MIN/MAX, WORKER_AUTOSCALE
+	poolConfig := worker.PoolConfigFromEnv()
+	workerPool := worker.NewWorkerPool(sender, poolConfig.Size).
+		WithConfig(poolConfig).
+		WithBatching(worker.BatchConfigFromEnv()).
+		WithParking(worker.ParkConfigFromEnv()).
+		WithDelayedLimits(worker.DelayedLimitsFromEnv()).
+		WithPriorities(worker.PriorityConfigFromEnv()).
+		WithRetrySchedule(worker.RetryScheduleFromEnv())
 	workerCtx := context.Background()
 	workerPool.Start(workerCtx)
 
-	orderService := service.NewOrderService(dropiClient, workerPool)
+	// Alias de status por país opcionales (STATUS_ALIASES_FILE)
+	normalizer := models.NewStatusNormalizer()
+	if path := os.Getenv("STATUS_ALIASES_FILE"); path != "" {
+		normalizer, err = models.LoadStatusNormalizer(path)
+		if err != nil {
+			zap.L().Error("Failed to load status aliases", zap.Error(err))
+			os.Exit(1)
+		}
+	}
+
+	// Reglas de transición de status opcionales (TRANSITION_RULES_FILE)
+	rules := compare.DefaultTransitionRules()
+	if path := os.Getenv("TRANSITION_RULES_FILE"); path != "" {
+		rules, err = compare.LoadTransitionRules(path)
+		if err != nil {
+			zap.L().Error("Failed to load transition rules", zap.Error(err))
+			os.Exit(1)
+		}
+	}
+
+	// Snapshots de órdenes para detectar cambios de guía, transportadora,
+	// novedad y sticker (STATE_STORE_PATH, WATCHED_FIELDS)
+	snapshots, err := state.NewStoreFromEnv()
+	if err != nil {
+		zap.L().Error("Failed to load state store", zap.Error(err))
+		os.Exit(1)
+	}
+
+	// SLA de tiempo en status (SLA_RULES_FILE); por defecto GUIA_GENERADA 72h y NOVEDAD 48h
+	slaMonitor := sla.NewMonitor(sla.DefaultRules(), normalizer)
+	if path := os.Getenv("SLA_RULES_FILE"); path != "" {
+		slaMonitor, err = sla.LoadMonitor(path, normalizer)
+		if err != nil {
+			zap.L().Error("Failed to load SLA rules", zap.Error(err))
+			os.Exit(1)
+		}
+	}
+	// Incumplimientos ya notificados por destino (SLA_STATE_PATH)
+	slaMonitor, err = slaMonitor.WithStateFile(os.Getenv("SLA_STATE_PATH"))
+	if err != nil {
+		zap.L().Error("Failed to load SLA state", zap.Error(err))
+		os.Exit(1)
+	}
+
+	// Registry de tenants con integration keys cifradas (TENANT_KEY_FILE,
+	// TENANT_REGISTRY_PATH); sin clave maestra queda deshabilitado
+	tenants, err := tenant.NewRegistryFromEnv()
+	if err != nil {
+		zap.L().Error("Failed to load tenant registry", zap.Error(err))
+		os.Exit(1)
+	}
+	if tenants != nil {
+		// Un schedule que el scheduler no sabe ejecutar no se guarda
+		tenants.WithScheduleValidator(func(expr string) error {
+			_, err := scheduler.ParseSchedule(expr)
+			return err
+		})
+	}
+
+	orderService := service.NewOrderService(dropiClient, workerPool).
+		WithComparator(compare.NewComparator(normalizer).
+			WithRules(rules).
+			WithSnapshots(snapshots, compare.WatchedFieldsFromEnv())).
+		WithSLA(slaMonitor).
+		WithTenants(tenants)
 	processHandler := handlers.NewProcessHandler(orderService)
 
+	// Consultas periódicas de tenants (SCHEDULER_CONFIG_FILE); sin archivo no hay jobs
+	var jobs []scheduler.Job
+	historySize := 0
+	if path := os.Getenv("SCHEDULER_CONFIG_FILE"); path != "" {
+		jobs, historySize, err = scheduler.LoadJobs(path)
+		if err != nil {
+			zap.L().Error("Failed to load scheduler config", zap.Error(err))
+			os.Exit(1)
+		}
+	}
+	tenantScheduler := scheduler.NewScheduler(orderService.HandleOrderRequest, jobs, historySize)
+	// Tenants del registry con schedule, un job por país y destino (los del
+	// archivo tienen prioridad); se reprograman al editar el registry
+	if tenants != nil {
+		tenantJitter, err := scheduler.TenantJitterFromEnv()
+		if err != nil {
+			zap.L().Error("Failed to load scheduler config", zap.Error(err))
+			os.Exit(1)
+		}
+		fileJobs := jobs
+		// Un tenant mal configurado no impide arrancar: se programan los demás
+		tenantJobs, err := scheduler.TenantJobs(tenants.List(), tenantJitter, fileJobs)
+		if err != nil {
+			zap.L().Error("Failed to schedule registry tenants", zap.Error(err))
+		}
+		tenantScheduler.ReplaceTenantJobs(tenantJobs)
+		tenants.OnChange(func(list []tenant.Tenant) {
+			tenantJobs, err := scheduler.TenantJobs(list, tenantJitter, fileJobs)
+			if err != nil {
+				zap.L().Error("Failed to schedule registry tenants", zap.Error(err))
+			}
+			tenantScheduler.ReplaceTenantJobs(tenantJobs)
+		})
+	}
+	schedulerCtx, stopScheduler := context.WithCancel(context.Background())
+	tenantScheduler.Start(schedulerCtx)
+	schedulerHandler := handlers.NewSchedulerHandler(tenantScheduler)
+	adminHandler := handlers.NewAdminHandler(workerPool)
+	schemaHandler := handlers.NewSchemaHandler()
+	slaHandler := handlers.NewSLAHandler(slaMonitor)
+
+	// Autenticación de /process, /sla y /admin (AUTH_CONFIG_FILE): API keys
+	// estáticas con scopes y/o ID tokens OIDC de Google. Sin archivo solo se
+	// arranca con AUTH_DISABLED=true
+	authenticator, err := auth.AuthenticatorFromEnv()
+	if err != nil {
+		zap.L().Error("Failed to load auth config", zap.Error(err))
+		os.Exit(1)
+	}
+	if authenticator == nil {
+		zap.L().Warn("AUTH_DISABLED=true: /process and /sla are unauthenticated and /admin routes are not exposed")
+	}
+
 	//
 	// -----------------------
 	// HTTP ROUTES
 	// -----------------------
 	mux := http.NewServeMux()
-	mux.HandleFunc("/health", healthHandler)
-	mux.HandleFunc("/process", withLogging(processHandler.ProcessOrders))
+	mux.HandleFunc("/health", healthHandler(workerPool))
+	mux.HandleFunc("/process", withLogging(withAuth(authenticator, auth.ScopeProcess, processHandler.ProcessOrders)))
+	mux.HandleFunc("/process/batch", withLogging(withAuth(authenticator, auth.ScopeProcess, processHandler.ProcessBatch)))
+	mux.HandleFunc("/schemas/webhook/", schemaHandler.WebhookSchema)
+	mux.HandleFunc("/sla/breaches", withLogging(withAuth(authenticator, auth.ScopeSLARead, slaHandler.Breaches)))
+	// Las rutas de administración (workers, scheduler, tenants con sus
+	// integration keys) nunca se exponen sin autenticación
+	if authenticator != nil {
+		mux.HandleFunc("/admin/workers", withLogging(withAuth(authenticator, auth.ScopeAdmin, adminHandler.Workers)))
+		mux.HandleFunc("/admin/scheduler", withLogging(withAuth(authenticator, auth.ScopeAdmin, schedulerHandler.Jobs)))
+		if tenants != nil {
+			tenantHandler := handlers.NewTenantHandler(tenants)
+			mux.HandleFunc("/admin/tenants", withLogging(withAuth(authenticator, auth.ScopeAdmin, tenantHandler.Tenants)))
+			mux.HandleFunc("/admin/tenants/", withLogging(withAuth(authenticator, auth.ScopeAdmin, tenantHandler.Tenant)))
+		}
+	}
 
 	server := &http.Server{
 		Addr:         ":" + port,
 		Handler:      mux,
 		ReadTimeout:  10 * time.Second,
-		WriteTimeout: 60 * time.Second,
+		WriteTimeout: 60 * time.Second, // /process/batch lo extiende; el stream NDJSON lo renueva por línea
 		IdleTimeout:  120 * time.Second,
 	}
 
@@ -95,6 +242,10 @@ func main() {
 
 		zap.L().Info("Shutting down gracefully...")
 
+		// No lanzar nuevas consultas programadas y esperar las que están en curso
+		stopScheduler()
+		tenantScheduler.Wait()
+
 		shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 		defer cancel()
 
@@ -102,6 +253,13 @@ func main() {
 			zap.L().Error("Graceful shutdown failed", zap.Error(err))
 		}
 
+		if err := snapshots.Flush(); err != nil {
+			zap.L().Error("Failed to save order snapshots", zap.Error(err))
+		}
+		if err := slaMonitor.Flush(); err != nil {
+			zap.L().Error("Failed to save SLA state", zap.Error(err))
+		}
+
 		zap.L().Info("Server exited")
 		os.Exit(0)
 	}()
@@ -152,24 +310,68 @@ func withLogging(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
+//
+// -------------------------------------------------------
+// MIDDLEWARE: Autenticación por ruta (API key u OIDC)
+// -------------------------------------------------------
+func withAuth(a *auth.Authenticator, scope string, next http.HandlerFunc) http.HandlerFunc {
+	if a == nil {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, err := a.Authorize(r, scope)
+		if err != nil {
+			traceID, _ := r.Context().Value(traceIDKey).(string)
+			zap.L().Warn("Request rejected by auth",
+				zap.String("trace_id", traceID),
+				zap.String("path", r.URL.Path),
+				zap.String("scope", scope),
+				zap.Error(err),
+			)
+
+			if errors.Is(err, auth.ErrForbidden) {
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+			w.Header().Set("WWW-Authenticate", `Bearer realm="dropi-order-status-service"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		next(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+	}
+}
+
 //
 // -------------------------------------------------------
 // HEALTH CHECK
 // -------------------------------------------------------
 type HealthResponse struct {
-	Status  string `json:"status"`
-	Service string `json:"service"`
-	Version string `json:"version"`
+	Status        string         `json:"status"`
+	Service       string         `json:"service"`
+	Version       string         `json:"version"`
+	QueueDepth    int            `json:"queue_depth"`
+	QueueCapacity int            `json:"queue_capacity"`
+	QueueReady    map[string]int `json:"queue_ready_by_priority"`
+	QueueWaiting  int            `json:"queue_waiting"`
+	QueueDelayed  int            `json:"queue_scheduled_retries"`
 }
 
-func healthHandler(w http.ResponseWriter, r *http.Request) {
-	resp := HealthResponse{
-		Status:  "healthy",
-		Service: "dropi-order-status-service",
-		Version: "1.0.0",
-	}
+func healthHandler(pool *worker.WorkerPool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp := HealthResponse{
+			Status:        "healthy",
+			Service:       "dropi-order-status-service",
+			Version:       "1.0.0",
+			QueueDepth:    pool.QueueDepth(),
+			QueueCapacity: pool.QueueCapacity(),
+			QueueReady:    pool.QueueDepthByPriority(),
+			QueueWaiting:  pool.WaitingDepth(),
+			QueueDelayed:  pool.ScheduledDepth(),
+		}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}
 }
 
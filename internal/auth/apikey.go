@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+type apiKeyConfig struct {
+	Name   string   `json:"name"`
+	SHA256 string   `json:"sha256"`
+	Scopes []string `json:"scopes"`
+}
+
+type apiKey struct {
+	name   string
+	hash   []byte
+	scopes []string
+}
+
+// APIKeys keys estáticas identificadas por su hash SHA-256.
+type APIKeys struct {
+	keys []apiKey
+}
+
+func newAPIKeys(configs []apiKeyConfig) (*APIKeys, error) {
+	k := &APIKeys{}
+	names := make(map[string]bool, len(configs))
+	for _, c := range configs {
+		if c.Name == "" {
+			return nil, fmt.Errorf("api key name is required")
+		}
+		if names[c.Name] {
+			return nil, fmt.Errorf("duplicate api key %q", c.Name)
+		}
+		names[c.Name] = true
+
+		hash, err := hex.DecodeString(strings.TrimSpace(c.SHA256))
+		if err != nil || len(hash) != sha256.Size {
+			return nil, fmt.Errorf("api key %q: sha256 must be 64 hex characters", c.Name)
+		}
+		if len(c.Scopes) == 0 {
+			return nil, fmt.Errorf("api key %q: at least one scope is required", c.Name)
+		}
+		k.keys = append(k.keys, apiKey{name: c.Name, hash: hash, scopes: c.Scopes})
+	}
+	return k, nil
+}
+
+// Lookup busca la key comparando hashes en tiempo constante.
+func (k *APIKeys) Lookup(key string) (*Principal, error) {
+	sum := sha256.Sum256([]byte(key))
+
+	var found *apiKey
+	for i := range k.keys {
+		// Se recorren todas para no filtrar por tiempo cuál coincidió
+		if subtle.ConstantTimeCompare(sum[:], k.keys[i].hash) == 1 {
+			found = &k.keys[i]
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("%w: invalid API key", ErrUnauthenticated)
+	}
+	return &Principal{
+		Subject: found.name,
+		Method:  "api_key",
+		Scopes:  found.scopes,
+	}, nil
+}
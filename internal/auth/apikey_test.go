@@ -0,0 +1,104 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func testAPIKeys(t *testing.T) *APIKeys {
+	t.Helper()
+	keys, err := newAPIKeys([]apiKeyConfig{
+		{Name: "backoffice", SHA256: hashKey("secret-1"), Scopes: []string{ScopeProcess, ScopeSLARead}},
+		{Name: "ops", SHA256: strings.ToUpper(hashKey("secret-2")), Scopes: []string{ScopeAll}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
+
+func TestAPIKeysLookup(t *testing.T) {
+	keys := testAPIKeys(t)
+
+	p, err := keys.Lookup("secret-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Subject != "backoffice" || p.Method != "api_key" || !p.HasScope(ScopeSLARead) || p.HasScope(ScopeAdmin) {
+		t.Fatalf("unexpected principal %+v", p)
+	}
+
+	// El hash en mayúsculas también es hex válido; "*" da todos los scopes
+	p, err = keys.Lookup("secret-2")
+	if err != nil || !p.HasScope(ScopeAdmin) {
+		t.Fatalf("ops key: %+v, %v", p, err)
+	}
+
+	for _, key := range []string{"", "secret", "secret-1 ", hashKey("secret-1")} {
+		if _, err := keys.Lookup(key); !errors.Is(err, ErrUnauthenticated) {
+			t.Errorf("Lookup(%q): err = %v, want ErrUnauthenticated", key, err)
+		}
+	}
+}
+
+func TestNewAPIKeysValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		configs []apiKeyConfig
+	}{
+		{"no name", []apiKeyConfig{{SHA256: hashKey("a"), Scopes: []string{ScopeProcess}}}},
+		{"duplicate", []apiKeyConfig{
+			{Name: "a", SHA256: hashKey("a"), Scopes: []string{ScopeProcess}},
+			{Name: "a", SHA256: hashKey("b"), Scopes: []string{ScopeProcess}},
+		}},
+		{"plaintext key", []apiKeyConfig{{Name: "a", SHA256: "secret", Scopes: []string{ScopeProcess}}}},
+		{"short hash", []apiKeyConfig{{Name: "a", SHA256: hashKey("a")[:32], Scopes: []string{ScopeProcess}}}},
+		{"no scopes", []apiKeyConfig{{Name: "a", SHA256: hashKey("a")}}},
+	}
+	for _, tt := range tests {
+		if _, err := newAPIKeys(tt.configs); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
+
+func TestAuthenticatorAuthorize(t *testing.T) {
+	a := NewAuthenticator(testAPIKeys(t), nil)
+
+	tests := []struct {
+		name   string
+		header string
+		value  string
+		scope  string
+		want   error
+	}{
+		{"x-api-key", "X-API-Key", "secret-1", ScopeProcess, nil},
+		{"bearer key", "Authorization", "Bearer secret-1", ScopeProcess, nil},
+		{"lowercase bearer", "Authorization", "bearer secret-1", ScopeProcess, nil},
+		{"missing scope", "X-API-Key", "secret-1", ScopeAdmin, ErrForbidden},
+		{"wrong key", "X-API-Key", "nope", ScopeProcess, ErrUnauthenticated},
+		{"no credentials", "", "", ScopeProcess, ErrUnauthenticated},
+		{"basic auth", "Authorization", "Basic Zm9vOmJhcg==", ScopeProcess, ErrUnauthenticated},
+		// Con forma de JWT se exige OIDC, que no está habilitado
+		{"jwt without oidc", "Authorization", "Bearer a.b.c", ScopeProcess, ErrUnauthenticated},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("POST", "/process", nil)
+		if tt.header != "" {
+			r.Header.Set(tt.header, tt.value)
+		}
+		_, err := a.Authorize(r, tt.scope)
+		if tt.want == nil && err != nil || tt.want != nil && !errors.Is(err, tt.want) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
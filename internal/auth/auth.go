@@ -0,0 +1,189 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Scopes que exigen las rutas.
+const (
+	ScopeProcess = "process"  // POST /process
+	ScopeAdmin   = "admin"    // /admin/*
+	ScopeSLARead = "sla:read" // GET /sla/breaches
+	ScopeAll     = "*"
+)
+
+var (
+	// ErrUnauthenticated no hay credenciales o no son válidas (401)
+	ErrUnauthenticated = errors.New("unauthenticated")
+	// ErrForbidden credenciales válidas sin el scope requerido (403)
+	ErrForbidden = errors.New("forbidden")
+)
+
+// Principal quién hace el request.
+type Principal struct {
+	Subject string // nombre de la API key o email/sub del token
+	Method  string // api_key | oidc
+	Scopes  []string
+}
+
+// HasScope true si el principal tiene el scope (o "*").
+func (p *Principal) HasScope(scope string) bool {
+	for _, s := range p.Scopes {
+		if s == scope || s == ScopeAll {
+			return true
+		}
+	}
+	return false
+}
+
+type principalKey struct{}
+
+// WithPrincipal guarda el principal autenticado en el contexto.
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFromContext principal del request; nil si la ruta no exige auth.
+func PrincipalFromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalKey{}).(*Principal)
+	return p
+}
+
+// Authenticator valida API keys estáticas y ID tokens OIDC firmados por Google.
+type Authenticator struct {
+	keys *APIKeys
+	oidc *OIDCVerifier
+}
+
+func NewAuthenticator(keys *APIKeys, oidc *OIDCVerifier) *Authenticator {
+	return &Authenticator{keys: keys, oidc: oidc}
+}
+
+// Authenticate lee las credenciales del request:
+//   - X-API-Key: <key>
+//   - Authorization: Bearer <key | ID token>
+//
+// Un bearer con forma de JWT se verifica como ID token; si no, como API key.
+func (a *Authenticator) Authenticate(r *http.Request) (*Principal, error) {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return a.apiKey(key)
+	}
+
+	authz := r.Header.Get("Authorization")
+	if len(authz) < 7 || !strings.EqualFold(authz[:7], "Bearer ") {
+		return nil, fmt.Errorf("%w: missing credentials", ErrUnauthenticated)
+	}
+	token := strings.TrimSpace(authz[7:])
+
+	if strings.Count(token, ".") == 2 {
+		if a.oidc == nil {
+			return nil, fmt.Errorf("%w: ID tokens are not enabled", ErrUnauthenticated)
+		}
+		return a.oidc.Verify(r.Context(), token)
+	}
+	return a.apiKey(token)
+}
+
+func (a *Authenticator) apiKey(key string) (*Principal, error) {
+	if a.keys == nil {
+		return nil, fmt.Errorf("%w: API keys are not enabled", ErrUnauthenticated)
+	}
+	return a.keys.Lookup(key)
+}
+
+// Authorize autentica y verifica el scope.
+func (a *Authenticator) Authorize(r *http.Request, scope string) (*Principal, error) {
+	p, err := a.Authenticate(r)
+	if err != nil {
+		return nil, err
+	}
+	if !p.HasScope(scope) {
+		return p, fmt.Errorf("%w: %s lacks scope %q", ErrForbidden, p.Subject, scope)
+	}
+	return p, nil
+}
+
+// configFile formato de AUTH_CONFIG_FILE. Las API keys se guardan como
+// SHA-256 en hex (echo -n "$KEY" | sha256sum), nunca en claro.
+//
+//	{
+//	  "api_keys": [
+//	    {"name": "backoffice", "sha256": "9f86d0...", "scopes": ["process", "sla:read"]}
+//	  ],
+//	  "oidc": {
+//	    "audience": "https://dropi-status-xyz.a.run.app",
+//	    "jwks_url": "https://www.googleapis.com/oauth2/v3/certs",
+//	    "principals": [
+//	      {"email": "scheduler@proyecto.iam.gserviceaccount.com", "scopes": ["process"]}
+//	    ]
+//	  }
+//	}
+type configFile struct {
+	APIKeys []apiKeyConfig `json:"api_keys"`
+	OIDC    *oidcConfig    `json:"oidc"`
+}
+
+// ErrAuthNotConfigured no hay AUTH_CONFIG_FILE ni una desactivación explícita.
+var ErrAuthNotConfigured = errors.New("AUTH_CONFIG_FILE is required (AUTH_DISABLED=true runs without authentication)")
+
+// AuthenticatorFromEnv carga AUTH_CONFIG_FILE. Sin archivo la autenticación
+// solo se desactiva con AUTH_DISABLED=true (retorna nil, nil); en cualquier
+// otro caso falla, para no exponer los endpoints sin credenciales por
+// olvidar una variable.
+func AuthenticatorFromEnv() (*Authenticator, error) {
+	if path := os.Getenv("AUTH_CONFIG_FILE"); path != "" {
+		return LoadAuthenticator(path)
+	}
+
+	v := strings.TrimSpace(os.Getenv("AUTH_DISABLED"))
+	if v == "" {
+		return nil, ErrAuthNotConfigured
+	}
+	disabled, err := strconv.ParseBool(v)
+	if err != nil {
+		return nil, fmt.Errorf("invalid AUTH_DISABLED %q: %w", v, err)
+	}
+	if !disabled {
+		return nil, ErrAuthNotConfigured
+	}
+	return nil, nil
+}
+
+// LoadAuthenticator lee AUTH_CONFIG_FILE.
+func LoadAuthenticator(path string) (*Authenticator, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading auth config: %w", err)
+	}
+
+	var file configFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid auth config JSON: %w", err)
+	}
+
+	var keys *APIKeys
+	if len(file.APIKeys) > 0 {
+		if keys, err = newAPIKeys(file.APIKeys); err != nil {
+			return nil, err
+		}
+	}
+
+	var oidc *OIDCVerifier
+	if file.OIDC != nil {
+		if oidc, err = newOIDCVerifier(*file.OIDC); err != nil {
+			return nil, err
+		}
+	}
+
+	if keys == nil && oidc == nil {
+		return nil, errors.New("auth config has no api_keys and no oidc section")
+	}
+	return NewAuthenticator(keys, oidc), nil
+}
@@ -0,0 +1,47 @@
+package auth
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAuthenticatorFromEnv(t *testing.T) {
+	config := filepath.Join(t.TempDir(), "auth.json")
+	data := `{"api_keys": [{"name": "ops", "sha256": "` + hashKey("secret") + `", "scopes": ["*"]}]}`
+	if err := os.WriteFile(config, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		file     string
+		disabled string
+		wantAuth bool
+		wantErr  bool
+	}{
+		{"config file", config, "", true, false},
+		{"config file wins over disabled", config, "true", true, false},
+		// Sin archivo no se arranca abierto por omisión
+		{"nothing set", "", "", false, true},
+		{"disabled false", "", "false", false, true},
+		{"disabled garbage", "", "yes please", false, true},
+		{"explicit opt-out", "", "true", false, false},
+	}
+	for _, tt := range tests {
+		t.Setenv("AUTH_CONFIG_FILE", tt.file)
+		t.Setenv("AUTH_DISABLED", tt.disabled)
+
+		a, err := AuthenticatorFromEnv()
+		if (err != nil) != tt.wantErr || (a != nil) != tt.wantAuth {
+			t.Errorf("%s: authenticator = %v, err = %v", tt.name, a != nil, err)
+		}
+	}
+
+	t.Setenv("AUTH_CONFIG_FILE", "")
+	t.Setenv("AUTH_DISABLED", "")
+	if _, err := AuthenticatorFromEnv(); !errors.Is(err, ErrAuthNotConfigured) {
+		t.Fatalf("expected ErrAuthNotConfigured, got %v", err)
+	}
+}
@@ -0,0 +1,269 @@
+package auth
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// GoogleJWKSURL llaves con las que Google firma los ID tokens
+	GoogleJWKSURL = "https://www.googleapis.com/oauth2/v3/certs"
+
+	defaultJWKSTTL = time.Hour
+	// minJWKSRefresh evita pedir el JWKS en cada token con un kid desconocido
+	minJWKSRefresh = time.Minute
+	// jwksFetchTimeout la descarga no depende del request que la disparó
+	jwksFetchTimeout = 10 * time.Second
+
+	// Backoff tras descargas fallidas (5s, 10s, 20s... hasta 5m): mientras
+	// tanto los tokens con kid desconocido fallan sin volver a pedir el JWKS
+	jwksRetryBase = 5 * time.Second
+	jwksRetryMax  = 5 * time.Minute
+)
+
+// JWKSCache llaves públicas RSA por kid. Se refrescan al vencer el max-age
+// del Cache-Control o al llegar un kid desconocido (rotación de llaves). La
+// descarga corre fuera del lock y una sola a la vez: los requests que llegan
+// mientras tanto esperan su resultado.
+type JWKSCache struct {
+	url    string
+	client *http.Client
+	now    func() time.Time
+
+	mu        sync.Mutex
+	keys      map[string]*rsa.PublicKey
+	expires   time.Time
+	lastFetch time.Time
+	inflight  *jwksFetch
+	failures  int       // descargas fallidas seguidas
+	retryAt   time.Time // no volver a descargar antes (cache negativo)
+	lastErr   error
+}
+
+// jwksFetch descarga en curso; done se cierra al terminar.
+type jwksFetch struct {
+	done chan struct{}
+	err  error
+}
+
+// NewJWKSCache url puede ser un servidor local o file:///ruta/jwks.json para pruebas.
+func NewJWKSCache(url string, client *http.Client) *JWKSCache {
+	if client == nil {
+		client = &http.Client{Timeout: jwksFetchTimeout}
+	}
+	return &JWKSCache{url: url, client: client, now: time.Now}
+}
+
+// Key llave pública del kid.
+func (c *JWKSCache) Key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	c.mu.Lock()
+	now := c.now()
+	key, ok := c.keys[kid]
+	if ok && now.Before(c.expires) {
+		c.mu.Unlock()
+		return key, nil
+	}
+
+	f := c.inflight
+	if f == nil {
+		if !c.shouldRefresh(now) {
+			err := c.lastErr
+			c.mu.Unlock()
+			// Con llaves previas se sigue validando mientras el JWKS no responde
+			if ok {
+				return key, nil
+			}
+			if err != nil && now.Before(c.retryAt) {
+				return nil, fmt.Errorf("JWKS unavailable: %w", err)
+			}
+			return nil, fmt.Errorf("unknown key id %q", kid)
+		}
+		f = &jwksFetch{done: make(chan struct{})}
+		c.inflight = f
+		c.lastFetch = now
+		go c.refresh(f)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-f.done:
+	case <-ctx.Done():
+		if ok {
+			return key, nil
+		}
+		return nil, ctx.Err()
+	}
+
+	c.mu.Lock()
+	key, ok = c.keys[kid]
+	c.mu.Unlock()
+	switch {
+	case ok:
+		return key, nil
+	case f.err != nil:
+		return nil, f.err
+	default:
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+}
+
+// shouldRefresh vencido o kid nuevo: refrescar, salvo que se haya hecho hace
+// muy poco o se esté esperando el backoff de una falla; requiere c.mu.
+func (c *JWKSCache) shouldRefresh(now time.Time) bool {
+	if now.Before(c.retryAt) {
+		return false
+	}
+	if c.keys == nil || !now.Before(c.expires) {
+		return true
+	}
+	return now.Sub(c.lastFetch) >= minJWKSRefresh
+}
+
+// refresh descarga y parsea el JWKS sin el lock y publica el resultado.
+func (c *JWKSCache) refresh(f *jwksFetch) {
+	ctx, cancel := context.WithTimeout(context.Background(), jwksFetchTimeout)
+	defer cancel()
+
+	keys, maxAge, err := c.load(ctx)
+
+	c.mu.Lock()
+	now := c.now()
+	if err != nil {
+		c.failures++
+		c.lastErr = err
+		c.retryAt = now.Add(jwksRetryDelay(c.failures))
+	} else {
+		c.failures = 0
+		c.lastErr = nil
+		c.retryAt = time.Time{}
+		c.keys = keys
+		c.expires = now.Add(maxAge)
+	}
+	c.inflight = nil
+	c.mu.Unlock()
+
+	f.err = err
+	close(f.done)
+}
+
+// jwksRetryDelay backoff exponencial tras n fallas seguidas.
+func jwksRetryDelay(n int) time.Duration {
+	d := jwksRetryBase
+	for i := 1; i < n && d < jwksRetryMax; i++ {
+		d *= 2
+	}
+	if d > jwksRetryMax {
+		d = jwksRetryMax
+	}
+	return d
+}
+
+// load descarga el JWKS y retorna sus llaves RSA de firma y el max-age.
+func (c *JWKSCache) load(ctx context.Context) (map[string]*rsa.PublicKey, time.Duration, error) {
+	body, cacheControl, err := c.fetch(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var set struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			Alg string `json:"alg"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := json.Unmarshal(body, &set); err != nil {
+		return nil, 0, fmt.Errorf("invalid JWKS: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		pub, err := rsaPublicKey(k.N, k.E)
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid JWKS key %q: %w", k.Kid, err)
+		}
+		keys[k.Kid] = pub
+	}
+	if len(keys) == 0 {
+		return nil, 0, fmt.Errorf("JWKS has no RSA signing keys")
+	}
+	return keys, cacheMaxAge(cacheControl), nil
+}
+
+// fetch lee el JWKS por HTTP o, con file://, de un archivo local (fixtures).
+func (c *JWKSCache) fetch(ctx context.Context) ([]byte, string, error) {
+	if path := strings.TrimPrefix(c.url, "file://"); path != c.url {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, "", fmt.Errorf("error reading JWKS: %w", err)
+		}
+		return data, "", nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("error creating JWKS request: %w", err)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("error fetching JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("JWKS endpoint returned %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, "", fmt.Errorf("error reading JWKS: %w", err)
+	}
+	return data, resp.Header.Get("Cache-Control"), nil
+}
+
+func rsaPublicKey(n, e string) (*rsa.PublicKey, error) {
+	nb, err := base64.RawURLEncoding.DecodeString(n)
+	if err != nil {
+		return nil, fmt.Errorf("modulus: %w", err)
+	}
+	eb, err := base64.RawURLEncoding.DecodeString(e)
+	if err != nil {
+		return nil, fmt.Errorf("exponent: %w", err)
+	}
+	exp := new(big.Int).SetBytes(eb)
+	if !exp.IsInt64() || exp.Int64() < 3 || exp.Int64() > 1<<31-1 {
+		return nil, fmt.Errorf("unsupported exponent")
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(nb), E: int(exp.Int64())}, nil
+}
+
+// cacheMaxAge max-age del Cache-Control; defaultJWKSTTL si no viene.
+func cacheMaxAge(header string) time.Duration {
+	for _, directive := range strings.Split(header, ",") {
+		directive = strings.TrimSpace(directive)
+		if !strings.HasPrefix(directive, "max-age=") {
+			continue
+		}
+		secs, err := strconv.Atoi(strings.TrimPrefix(directive, "max-age="))
+		if err == nil && secs > 0 {
+			return time.Duration(secs) * time.Second
+		}
+	}
+	return defaultJWKSTTL
+}
@@ -0,0 +1,203 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// jwksServer sirve testdata/jwks.json; con fail responde 503. release, si no
+// es nil, retiene las respuestas hasta cerrarse.
+type jwksServer struct {
+	*httptest.Server
+	hits    int32
+	fail    atomic.Bool
+	release chan struct{}
+}
+
+func newJWKSServer(t *testing.T, release chan struct{}) *jwksServer {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "jwks.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &jwksServer{release: release}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&s.hits, 1)
+		if s.release != nil {
+			<-s.release
+		}
+		if s.fail.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Cache-Control", "public, max-age=600")
+		w.Write(data)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestJWKSCacheFetchesOnceForConcurrentRequests(t *testing.T) {
+	release := make(chan struct{})
+	srv := newJWKSServer(t, release)
+	c := NewJWKSCache(srv.URL, nil)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := c.Key(context.Background(), testKid)
+			errs <- err
+		}()
+	}
+
+	// Mientras la descarga está retenida el lock queda libre
+	time.Sleep(50 * time.Millisecond)
+	c.mu.Lock()
+	inflight := c.inflight != nil
+	c.mu.Unlock()
+	if !inflight {
+		t.Fatal("expected a fetch in flight")
+	}
+	close(release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if hits := atomic.LoadInt32(&srv.hits); hits != 1 {
+		t.Fatalf("expected 1 JWKS fetch, got %d", hits)
+	}
+	if got := c.expires.Sub(c.lastFetch); got < 9*time.Minute || got > 11*time.Minute {
+		t.Fatalf("Cache-Control max-age not applied: %v", got)
+	}
+}
+
+func TestJWKSCacheWaiterHonoursContext(t *testing.T) {
+	release := make(chan struct{})
+	srv := newJWKSServer(t, release)
+	defer close(release)
+	c := NewJWKSCache(srv.URL, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := c.Key(ctx, testKid); err != context.DeadlineExceeded {
+		t.Fatalf("expected DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestJWKSCacheBacksOffAfterFailure(t *testing.T) {
+	srv := newJWKSServer(t, nil)
+	srv.fail.Store(true)
+
+	now := testNow
+	c := NewJWKSCache(srv.URL, nil)
+	c.now = func() time.Time { return now }
+
+	if _, err := c.Key(context.Background(), testKid); err == nil {
+		t.Fatal("expected an error while the JWKS endpoint fails")
+	}
+	// Dentro del backoff no se vuelve a pedir el JWKS
+	for i := 0; i < 5; i++ {
+		if _, err := c.Key(context.Background(), testKid); err == nil {
+			t.Fatal("expected the cached failure")
+		}
+	}
+	if hits := atomic.LoadInt32(&srv.hits); hits != 1 {
+		t.Fatalf("expected 1 fetch during the backoff, got %d", hits)
+	}
+
+	// Pasado el backoff se reintenta y se recupera
+	srv.fail.Store(false)
+	now = now.Add(jwksRetryBase + time.Second)
+	if _, err := c.Key(context.Background(), testKid); err != nil {
+		t.Fatal(err)
+	}
+	if hits := atomic.LoadInt32(&srv.hits); hits != 2 {
+		t.Fatalf("expected a retry after the backoff, got %d fetches", hits)
+	}
+}
+
+func TestJWKSCacheKeepsKeysWhenRefreshFails(t *testing.T) {
+	srv := newJWKSServer(t, nil)
+	now := testNow
+	c := NewJWKSCache(srv.URL, nil)
+	c.now = func() time.Time { return now }
+
+	if _, err := c.Key(context.Background(), testKid); err != nil {
+		t.Fatal(err)
+	}
+
+	// Vence el max-age y el endpoint falla: la llave conocida sigue sirviendo
+	srv.fail.Store(true)
+	now = now.Add(11 * time.Minute)
+	if _, err := c.Key(context.Background(), testKid); err != nil {
+		t.Fatalf("known key should survive a failed refresh: %v", err)
+	}
+	if _, err := c.Key(context.Background(), "rotated"); err == nil {
+		t.Fatal("unknown kid should fail while the JWKS is unavailable")
+	}
+}
+
+func TestJWKSCacheUnknownKidRefreshLimit(t *testing.T) {
+	srv := newJWKSServer(t, nil)
+	now := testNow
+	c := NewJWKSCache(srv.URL, nil)
+	c.now = func() time.Time { return now }
+
+	if _, err := c.Key(context.Background(), testKid); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := c.Key(context.Background(), "rotated"); err == nil {
+			t.Fatal("unknown kid should fail")
+		}
+	}
+	if hits := atomic.LoadInt32(&srv.hits); hits != 1 {
+		t.Fatalf("unknown kids must not refetch within %v, got %d fetches", minJWKSRefresh, hits)
+	}
+
+	now = now.Add(minJWKSRefresh)
+	c.Key(context.Background(), "rotated")
+	if hits := atomic.LoadInt32(&srv.hits); hits != 2 {
+		t.Fatalf("expected a refetch after %v, got %d fetches", minJWKSRefresh, hits)
+	}
+}
+
+func TestJWKSRetryDelay(t *testing.T) {
+	want := []time.Duration{5 * time.Second, 10 * time.Second, 20 * time.Second, 40 * time.Second}
+	for i, w := range want {
+		if got := jwksRetryDelay(i + 1); got != w {
+			t.Errorf("failures %d: delay = %v, want %v", i+1, got, w)
+		}
+	}
+	if got := jwksRetryDelay(50); got != jwksRetryMax {
+		t.Errorf("delay must be capped at %v, got %v", jwksRetryMax, got)
+	}
+}
+
+func TestCacheMaxAge(t *testing.T) {
+	tests := map[string]time.Duration{
+		"":                               defaultJWKSTTL,
+		"public, max-age=19732, must-re": 19732 * time.Second,
+		"max-age=0":                      defaultJWKSTTL,
+		"no-cache":                       defaultJWKSTTL,
+	}
+	for header, want := range tests {
+		if got := cacheMaxAge(header); got != want {
+			t.Errorf("cacheMaxAge(%q) = %v, want %v", header, got, want)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// clockSkew tolerancia de reloj para exp, iat y nbf
+const clockSkew = time.Minute
+
+// GoogleIssuers valores de iss de los ID tokens de Google.
+var GoogleIssuers = []string{"https://accounts.google.com", "accounts.google.com"}
+
+type oidcConfig struct {
+	Audience   string          `json:"audience"`
+	Audiences  []string        `json:"audiences"`
+	JWKSURL    string          `json:"jwks_url"`
+	Issuers    []string        `json:"issuers"`
+	Principals []oidcPrincipal `json:"principals"`
+}
+
+// oidcPrincipal cuenta autorizada, por email (verificado) o por sub.
+type oidcPrincipal struct {
+	Email   string   `json:"email"`
+	Subject string   `json:"subject"`
+	Scopes  []string `json:"scopes"`
+}
+
+// OIDCVerifier verifica ID tokens RS256 (firma, emisor, audiencia y
+// vigencia). Cualquier cuenta de Google puede emitir un token para nuestra
+// audiencia, así que solo se aceptan las cuentas listadas en principals.
+type OIDCVerifier struct {
+	audiences  []string
+	issuers    []string
+	jwks       *JWKSCache
+	principals []oidcPrincipal
+	now        func() time.Time
+}
+
+func newOIDCVerifier(c oidcConfig) (*OIDCVerifier, error) {
+	audiences := c.Audiences
+	if c.Audience != "" {
+		audiences = append([]string{c.Audience}, audiences...)
+	}
+	if len(audiences) == 0 {
+		return nil, errors.New("oidc audience is required")
+	}
+	if len(c.Principals) == 0 {
+		return nil, errors.New("oidc principals are required")
+	}
+	for _, p := range c.Principals {
+		if p.Email == "" && p.Subject == "" {
+			return nil, errors.New("oidc principal needs email or subject")
+		}
+		if len(p.Scopes) == 0 {
+			return nil, fmt.Errorf("oidc principal %s%s: at least one scope is required", p.Email, p.Subject)
+		}
+	}
+
+	jwksURL := c.JWKSURL
+	if jwksURL == "" {
+		jwksURL = GoogleJWKSURL
+	}
+	issuers := c.Issuers
+	if len(issuers) == 0 {
+		issuers = GoogleIssuers
+	}
+
+	return &OIDCVerifier{
+		audiences:  audiences,
+		issuers:    issuers,
+		jwks:       NewJWKSCache(jwksURL, nil),
+		principals: c.Principals,
+		now:        time.Now,
+	}, nil
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+type idTokenClaims struct {
+	Issuer        string   `json:"iss"`
+	Subject       string   `json:"sub"`
+	Audience      audience `json:"aud"`
+	Expiry        int64    `json:"exp"`
+	IssuedAt      int64    `json:"iat"`
+	NotBefore     int64    `json:"nbf"`
+	Email         string   `json:"email"`
+	EmailVerified bool     `json:"email_verified"`
+}
+
+// audience aud puede ser un string o una lista.
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
+
+// Verify valida el token y retorna el principal autorizado.
+func (v *OIDCVerifier) Verify(ctx context.Context, token string) (*Principal, error) {
+	claims, err := v.verifyToken(ctx, token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnauthenticated, err)
+	}
+
+	for _, p := range v.principals {
+		if p.Email != "" && claims.EmailVerified && strings.EqualFold(p.Email, claims.Email) {
+			return &Principal{Subject: claims.Email, Method: "oidc", Scopes: p.Scopes}, nil
+		}
+		if p.Subject != "" && p.Subject == claims.Subject {
+			return &Principal{Subject: claims.Subject, Method: "oidc", Scopes: p.Scopes}, nil
+		}
+	}
+	subject := claims.Email
+	if subject == "" {
+		subject = claims.Subject
+	}
+	return nil, fmt.Errorf("%w: %s is not an allowed principal", ErrForbidden, subject)
+}
+
+func (v *OIDCVerifier) verifyToken(ctx context.Context, token string) (*idTokenClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("invalid token header: %w", err)
+	}
+	// Solo RS256: evita la confusión de algoritmos (none, HS256 con la llave pública)
+	if header.Alg != "RS256" {
+		return nil, fmt.Errorf("unsupported token algorithm %q", header.Alg)
+	}
+
+	key, err := v.jwks.Key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("invalid token signature encoding")
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig); err != nil {
+		return nil, errors.New("invalid token signature")
+	}
+
+	var claims idTokenClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("invalid token claims: %w", err)
+	}
+
+	if !contains(v.issuers, claims.Issuer) {
+		return nil, fmt.Errorf("unexpected issuer %q", claims.Issuer)
+	}
+	if !v.audienceAllowed(claims.Audience) {
+		return nil, errors.New("token audience does not match")
+	}
+
+	now := v.now()
+	if claims.Expiry == 0 || now.After(time.Unix(claims.Expiry, 0).Add(clockSkew)) {
+		return nil, errors.New("token expired")
+	}
+	if claims.IssuedAt != 0 && now.Add(clockSkew).Before(time.Unix(claims.IssuedAt, 0)) {
+		return nil, errors.New("token issued in the future")
+	}
+	if claims.NotBefore != 0 && now.Add(clockSkew).Before(time.Unix(claims.NotBefore, 0)) {
+		return nil, errors.New("token not valid yet")
+	}
+	return &claims, nil
+}
+
+func (v *OIDCVerifier) audienceAllowed(aud audience) bool {
+	for _, a := range aud {
+		if contains(v.audiences, a) {
+			return true
+		}
+	}
+	return false
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func contains(list []string, v string) bool {
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,211 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testAudience = "https://dropi-status.example.run.app"
+	testKid      = "test-key-1" // kid de testdata/jwks.json
+)
+
+var testNow = time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+
+// testSigningKey llave privada que corresponde a testdata/jwks.json (solo tests).
+func testSigningKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "jwks_private_key.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		t.Fatal("invalid PEM fixture")
+	}
+	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func fixtureJWKSURL(t *testing.T) string {
+	t.Helper()
+	path, err := filepath.Abs(filepath.Join("testdata", "jwks.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return "file://" + path
+}
+
+// signToken arma un JWT con header y claims dados, firmado RS256 con key.
+func signToken(t *testing.T, key *rsa.PrivateKey, header, claims map[string]interface{}) string {
+	t.Helper()
+	segment := func(v interface{}) string {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	signed := segment(header) + "." + segment(claims)
+	digest := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func validClaims() map[string]interface{} {
+	return map[string]interface{}{
+		"iss":            "https://accounts.google.com",
+		"sub":            "1234567890",
+		"aud":            testAudience,
+		"iat":            testNow.Add(-time.Minute).Unix(),
+		"exp":            testNow.Add(time.Hour).Unix(),
+		"email":          "scheduler@proyecto.iam.gserviceaccount.com",
+		"email_verified": true,
+	}
+}
+
+func testVerifier(t *testing.T) *OIDCVerifier {
+	t.Helper()
+	v, err := newOIDCVerifier(oidcConfig{
+		Audience: testAudience,
+		JWKSURL:  fixtureJWKSURL(t),
+		Principals: []oidcPrincipal{
+			{Email: "scheduler@proyecto.iam.gserviceaccount.com", Scopes: []string{ScopeProcess}},
+			{Subject: "999", Scopes: []string{ScopeAdmin}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.now = func() time.Time { return testNow }
+	return v
+}
+
+func TestOIDCVerifyAcceptsValidToken(t *testing.T) {
+	v := testVerifier(t)
+	key := testSigningKey(t)
+	header := map[string]interface{}{"alg": "RS256", "kid": testKid}
+
+	p, err := v.Verify(context.Background(), signToken(t, key, header, validClaims()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Method != "oidc" || p.Subject != "scheduler@proyecto.iam.gserviceaccount.com" || !p.HasScope(ScopeProcess) {
+		t.Fatalf("unexpected principal %+v", p)
+	}
+
+	// Principal por sub y aud como lista
+	claims := validClaims()
+	claims["sub"], claims["email"] = "999", ""
+	claims["aud"] = []string{"other", testAudience}
+	p, err = v.Verify(context.Background(), signToken(t, key, header, claims))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Subject != "999" || !p.HasScope(ScopeAdmin) {
+		t.Fatalf("unexpected principal %+v", p)
+	}
+}
+
+func TestOIDCVerifyRejects(t *testing.T) {
+	v := testVerifier(t)
+	key := testSigningKey(t)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := map[string]interface{}{"alg": "RS256", "kid": testKid}
+
+	with := func(field string, value interface{}) map[string]interface{} {
+		c := validClaims()
+		c[field] = value
+		return c
+	}
+	valid := signToken(t, key, header, validClaims())
+	parts := strings.Split(valid, ".")
+
+	tests := []struct {
+		name  string
+		token string
+		want  error
+	}{
+		{"malformed", "a.b", ErrUnauthenticated},
+		{"alg none", signToken(t, key, map[string]interface{}{"alg": "none", "kid": testKid}, validClaims()), ErrUnauthenticated},
+		{"alg HS256", signToken(t, key, map[string]interface{}{"alg": "HS256", "kid": testKid}, validClaims()), ErrUnauthenticated},
+		{"unknown kid", signToken(t, key, map[string]interface{}{"alg": "RS256", "kid": "other"}, validClaims()), ErrUnauthenticated},
+		{"wrong signer", signToken(t, otherKey, header, validClaims()), ErrUnauthenticated},
+		{"tampered claims", parts[0] + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"999"}`)) + "." + parts[2], ErrUnauthenticated},
+		{"issuer", signToken(t, key, header, with("iss", "https://evil.example.com")), ErrUnauthenticated},
+		{"audience", signToken(t, key, header, with("aud", "https://other.run.app")), ErrUnauthenticated},
+		{"expired", signToken(t, key, header, with("exp", testNow.Add(-2*time.Minute).Unix())), ErrUnauthenticated},
+		{"no exp", signToken(t, key, header, with("exp", 0)), ErrUnauthenticated},
+		{"issued in the future", signToken(t, key, header, with("iat", testNow.Add(5*time.Minute).Unix())), ErrUnauthenticated},
+		{"not valid yet", signToken(t, key, header, with("nbf", testNow.Add(5*time.Minute).Unix())), ErrUnauthenticated},
+		// Token válido de una cuenta que no está en principals
+		{"unknown principal", signToken(t, key, header, with("email", "someone@gmail.com")), ErrForbidden},
+		{"unverified email", signToken(t, key, header, with("email_verified", false)), ErrForbidden},
+	}
+	for _, tt := range tests {
+		_, err := v.Verify(context.Background(), tt.token)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestOIDCClockSkew(t *testing.T) {
+	v := testVerifier(t)
+	key := testSigningKey(t)
+	claims := validClaims()
+	claims["exp"] = testNow.Add(-30 * time.Second).Unix()
+
+	token := signToken(t, key, map[string]interface{}{"alg": "RS256", "kid": testKid}, claims)
+	if _, err := v.Verify(context.Background(), token); err != nil {
+		t.Fatalf("token expired within the clock skew should pass: %v", err)
+	}
+}
+
+func TestNewOIDCVerifierValidation(t *testing.T) {
+	principals := []oidcPrincipal{{Email: "a@b.com", Scopes: []string{ScopeProcess}}}
+	tests := []struct {
+		name string
+		cfg  oidcConfig
+	}{
+		{"no audience", oidcConfig{Principals: principals}},
+		{"no principals", oidcConfig{Audience: testAudience}},
+		{"principal without id", oidcConfig{Audience: testAudience, Principals: []oidcPrincipal{{Scopes: []string{ScopeProcess}}}}},
+		{"principal without scopes", oidcConfig{Audience: testAudience, Principals: []oidcPrincipal{{Email: "a@b.com"}}}},
+	}
+	for _, tt := range tests {
+		if _, err := newOIDCVerifier(tt.cfg); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+
+	v, err := newOIDCVerifier(oidcConfig{Audience: testAudience, Principals: principals})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.jwks.url != GoogleJWKSURL || !contains(v.issuers, "https://accounts.google.com") {
+		t.Fatal("defaults should point to Google")
+	}
+}
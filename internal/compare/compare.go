@@ -3,41 +3,190 @@ package compare
 import (
 	"errors"
 	"log/slog"
+	"os"
+	"strings"
 
 	"github.com/juancollazo-ch/dropi-order-status-service/internal/models" // Ajusta esta ruta según tu estructura real
+	"github.com/juancollazo-ch/dropi-order-status-service/internal/state"
 )
 
 // Result describe el resultado de la comparación
 type Result struct {
-	Changed      bool     // true si hubo cambio
-	OldStatus    string   // status del penúltimo item
-	NewStatus    string   // status del último item
-	OrderID      int64    // id de la orden
-	ProductNames []string // nombres de los productos en la orden
-	HistorySize  int      // total de items en el history
+	Changed       bool               // true si cambió el status o algún campo vigilado
+	StatusChanged bool               // true si cambió el status
+	OldStatus     string             // status del penúltimo item
+	NewStatus     string             // status del último item
+	OldCode       models.OrderStatus // status canónico del penúltimo item
+	NewCode       models.OrderStatus // status canónico del último item
+	OrderID       int64              // id de la orden
+	ProductNames  []string           // nombres de los productos en la orden
+	HistorySize   int                // total de items en el history
+
+	// Transition clasificación del cambio (solo si StatusChanged)
+	Transition TransitionKind
+	// Action qué hacer con el webhook según las reglas de transición
+	Action TransitionAction
+	// FieldChanges diferencias contra el snapshot guardado (incluye el status si cambió)
+	FieldChanges []models.FieldChange
+	// Anomalies problemas encontrados al ordenar el history (desorden, duplicados, ...)
+	Anomalies []models.HistoryAnomaly
+	// NewOrder primera vez que el destino ve la orden: no está en el store de
+	// snapshots, que debe ser persistente, y el scope ya fue sembrado (ver
+	// MarkSeeded). Sin store, en memoria o en la primera ejecución es false.
+	NewOrder bool
+}
+
+// Comparator compara estados usando un normalizador de status, así las
+// diferencias cosméticas de grafía entre países no cuentan como cambio.
+type Comparator struct {
+	normalizer *models.StatusNormalizer
+	rules      *TransitionRules
+	snapshots  state.Store
+	watched    []string
+}
+
+// NewComparator crea un comparador; con normalizer nil usa los alias por defecto.
+func NewComparator(normalizer *models.StatusNormalizer) *Comparator {
+	if normalizer == nil {
+		normalizer = models.NewStatusNormalizer()
+	}
+	return &Comparator{
+		normalizer: normalizer,
+		rules:      DefaultTransitionRules(),
+	}
+}
+
+// Normalizer normalizador de status del comparador.
+func (c *Comparator) Normalizer() *models.StatusNormalizer {
+	return c.normalizer
+}
+
+// WithRules reemplaza las reglas de transición.
+func (c *Comparator) WithRules(rules *TransitionRules) *Comparator {
+	if rules != nil {
+		c.rules = rules
+	}
+	return c
+}
+
+// WithSnapshots habilita la detección de cambios en los campos vigilados
+// comparando contra el último snapshot guardado de cada orden.
+func (c *Comparator) WithSnapshots(store state.Store, fields []string) *Comparator {
+	c.snapshots = store
+	c.watched = fields
+	return c
+}
+
+// WatchedFieldsFromEnv lee WATCHED_FIELDS (lista separada por coma, ej.
+// "shipping_guide,novedad_servientrega"); "none" deshabilita la detección.
+func WatchedFieldsFromEnv() []string {
+	v, ok := os.LookupEnv("WATCHED_FIELDS")
+	if !ok || strings.TrimSpace(v) == "" {
+		return models.DefaultWatchedFields
+	}
+	if strings.EqualFold(strings.TrimSpace(v), "none") {
+		return nil
+	}
+
+	var fields []string
+	for _, part := range strings.Split(v, ",") {
+		field := strings.ToLower(strings.TrimSpace(part))
+		if field == "" {
+			continue
+		}
+		if !models.IsWatchableField(field) {
+			slog.Warn("WATCHED_FIELDS: campo desconocido ignorado", "field", field)
+			continue
+		}
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+// Commit guarda el snapshot actual de la orden; llamarlo cuando el cambio ya
+// fue atendido, así un webhook que no se pudo encolar se detecta de nuevo.
+func (c *Comparator) Commit(order *models.DropiOrder, scope state.Scope) error {
+	if c.snapshots == nil {
+		return nil
+	}
+	return c.snapshots.Put(state.SnapshotKey(scope, order.ID), order.Snapshot())
+}
+
+// Seeded indica si el scope ya tuvo una ejecución completa con un store
+// persistente; antes de eso ninguna orden se reporta como nueva.
+func (c *Comparator) Seeded(scope state.Scope) bool {
+	if c.snapshots == nil || !c.snapshots.Persistent() {
+		return false
+	}
+	_, ok, err := c.snapshots.Get(state.SeededKey(scope))
+	return err == nil && ok
+}
+
+// MarkSeeded marca el scope como sembrado al terminar una ejecución completa:
+// la primera guarda las órdenes existentes sin anunciarlas y las siguientes
+// reportan como nuevas las que no tengan snapshot.
+func (c *Comparator) MarkSeeded(scope state.Scope) error {
+	if c.snapshots == nil || !c.snapshots.Persistent() || c.Seeded(scope) {
+		return nil
+	}
+	return c.snapshots.Put(state.SeededKey(scope), models.OrderSnapshot{})
+}
+
+// Flush persiste los snapshots pendientes.
+func (c *Comparator) Flush() error {
+	if c.snapshots == nil {
+		return nil
+	}
+	return c.snapshots.Flush()
 }
 
 // CompareOrderStatus evalúa si hubo cambio entre el último y penúltimo estado
 func CompareOrderStatus(order *models.DropiOrder, logger *slog.Logger) (Result, error) {
+	return NewComparator(nil).Compare(order, state.Scope{}, logger)
+}
+
+// Compare evalúa si hubo cambio entre el último y penúltimo estado. scope
+// identifica el snapshot (tenant, país y destino); su Country es el
+// dropi_country_suffix, usado para los alias de status por país. Además deja
+// el status canónico en order.NormalizedStatus.
+func (c *Comparator) Compare(order *models.DropiOrder, scope state.Scope, logger *slog.Logger) (Result, error) {
+	country := scope.Country
 
 	if order == nil {
 		logger.Error("compare: order is nil")
 		return Result{}, errors.New("order is nil")
 	}
 
+	order.NormalizedStatus = c.normalizer.Normalize(order.Status, country)
+
+	// Dropi no garantiza el orden del history y a veces repite items
+	history, anomalies := models.NormalizeHistory(order.History)
+	order.History = history
+	if len(anomalies) > 0 {
+		logger.Warn("compare: history anomalies",
+			"order_id", order.ID,
+			"anomalies", anomalies,
+		)
+	}
+
 	hSize := len(order.History)
 
-	// Necesitamos al menos 2 items para comparar
-	if hSize < 2 {
-		logger.Warn("compare: insufficient history length",
+	if hSize == 0 {
+		logger.Warn("compare: empty history",
 			"order_id", order.ID,
-			"history_items", hSize,
 		)
-		return Result{}, errors.New("history must contain at least 2 records")
+		return Result{}, errors.New("history must contain at least 1 record")
 	}
 
+	// Con un solo item no hay status anterior: solo puede ser una orden nueva
 	last := order.History[hSize-1]
-	prev := order.History[hSize-2]
+	prev := last
+	if hSize > 1 {
+		prev = order.History[hSize-2]
+	}
+
+	oldCode := c.normalizer.Normalize(prev.Status, country)
+	newCode := c.normalizer.Normalize(last.Status, country)
 
 	logger.Info("compare: comparing history states",
 		"order_id", order.ID,
@@ -45,28 +194,122 @@ func CompareOrderStatus(order *models.DropiOrder, logger *slog.Logger) (Result,
 		"last_status", last.Status,
 	)
 
-	changed := last.Status != prev.Status
+	statusChanged := oldCode != newCode
+	fieldChanges, seen := c.diffFields(order, scope, logger)
+	// Solo un store persistente sabe qué órdenes ya se vieron antes de un
+	// reinicio, y solo después de sembrar el scope: la primera ejecución no
+	// anuncia como nuevas las órdenes que ya existían
+	newOrder := !seen && c.Seeded(scope)
+	if newOrder {
+		logger.Info("compare: new order",
+			"order_id", order.ID,
+			"status", newCode,
+		)
+	}
+	if statusChanged {
+		fieldChanges = append([]models.FieldChange{{
+			Field: models.FieldStatus,
+			Old:   prev.Status,
+			New:   last.Status,
+		}}, fieldChanges...)
+	}
+	order.FieldChanges = fieldChanges
+	order.Transition = nil
+	if statusChanged {
+		order.Transition = models.NewStatusTransition(prev, last, oldCode, newCode)
+	}
+	changed := statusChanged || len(fieldChanges) > 0
+
+	var transition TransitionKind
+	action := ActionDeliver
 
 	// Log informativo claro
-	if changed {
+	if statusChanged {
+		transition = c.rules.Classify(oldCode, newCode)
+		action = c.rules.ActionFor(transition)
+
 		logger.Info("compare: status change detected",
 			"order_id", order.ID,
-			"from", prev.Status,
-			"to", last.Status,
+			"from", oldCode,
+			"to", newCode,
+			"transition", transition,
 		)
+
+		if transition != TransitionNormal {
+			logger.Warn("compare: suspicious status transition",
+				"order_id", order.ID,
+				"from", oldCode,
+				"to", newCode,
+				"transition", transition,
+				"action", action,
+			)
+		}
 	} else {
+		if prev.Status != last.Status {
+			logger.Info("compare: spelling difference ignored",
+				"order_id", order.ID,
+				"previous_status", prev.Status,
+				"last_status", last.Status,
+				"status_code", newCode,
+			)
+		}
 		logger.Info("compare: no change in status",
 			"order_id", order.ID,
 			"status", last.Status,
 		)
+		if changed {
+			logger.Info("compare: watched fields changed",
+				"order_id", order.ID,
+				"changes", len(fieldChanges),
+			)
+		}
+	}
+
+	oldStatus := prev.Status
+	if hSize == 1 {
+		oldStatus, oldCode = "", ""
 	}
 
 	return Result{
-		Changed:      changed,
-		OldStatus:    prev.Status,
-		NewStatus:    last.Status,
-		OrderID:      order.ID,
-		ProductNames: order.GetProductNames(),
-		HistorySize:  hSize,
+		Changed:       changed,
+		StatusChanged: statusChanged,
+		OldStatus:     oldStatus,
+		NewStatus:     last.Status,
+		OldCode:       oldCode,
+		NewCode:       newCode,
+		OrderID:       order.ID,
+		ProductNames:  order.GetProductNames(),
+		HistorySize:   hSize,
+		Transition:    transition,
+		Action:        action,
+		FieldChanges:  fieldChanges,
+		Anomalies:     anomalies,
+		NewOrder:      newOrder,
 	}, nil
 }
+
+// diffFields compara los campos vigilados contra el snapshot guardado. Una
+// orden sin snapshot previo no reporta cambios: solo se toma como base. seen
+// indica si la orden ya estaba en el store (ante un error de lectura se asume
+// que sí, para no reportarla como nueva).
+func (c *Comparator) diffFields(order *models.DropiOrder, scope state.Scope, logger *slog.Logger) (changes []models.FieldChange, seen bool) {
+	if c.snapshots == nil {
+		return nil, false
+	}
+
+	prev, ok, err := c.snapshots.Get(state.SnapshotKey(scope, order.ID))
+	if err == nil && !ok {
+		prev, ok, err = c.snapshots.Get(state.LegacySnapshotKey(scope.Country, order.ID))
+	}
+	if err != nil {
+		logger.Warn("compare: cannot read order snapshot",
+			"order_id", order.ID,
+			"error", err,
+		)
+		return nil, true
+	}
+	if !ok || len(c.watched) == 0 {
+		return nil, ok
+	}
+	return order.Snapshot().Diff(prev, c.watched), true
+}
@@ -0,0 +1,158 @@
+package compare
+
+import (
+	"io"
+	"log/slog"
+	"path/filepath"
+	"testing"
+
+	"github.com/juancollazo-ch/dropi-order-status-service/internal/models"
+	"github.com/juancollazo-ch/dropi-order-status-service/internal/state"
+)
+
+var testLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func testOrder(guide string) *models.DropiOrder {
+	return &models.DropiOrder{
+		ID:            42,
+		Status:        "EN TRANSITO",
+		ShippingGuide: guide,
+		History: []models.HistoryItem{
+			{ID: 1, Status: "GUIA_GENERADA", CreatedAt: "2024-01-01 10:00:00"},
+			{ID: 2, Status: "EN TRANSITO", CreatedAt: "2024-01-02 10:00:00"},
+		},
+	}
+}
+
+func fileStore(t *testing.T) *state.FileStore {
+	t.Helper()
+	store, err := state.NewFileStore(filepath.Join(t.TempDir(), "snapshots.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func TestSnapshotsAreScopedByTenantAndDestination(t *testing.T) {
+	store := fileStore(t)
+	c := NewComparator(nil).WithSnapshots(store, models.DefaultWatchedFields)
+
+	a := state.Scope{TenantID: "acme", Country: "co", Destination: "acme/a"}
+	b := state.Scope{TenantID: "acme", Country: "co", Destination: "acme/b"}
+	other := state.Scope{TenantID: "globex", Country: "co", Destination: "acme/a"}
+	for _, scope := range []state.Scope{a, b, other} {
+		if err := c.MarkSeeded(scope); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := c.Commit(testOrder("G1"), a); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := c.Compare(testOrder("G2"), a, testLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.NewOrder || len(res.FieldChanges) == 0 {
+		t.Fatalf("destination a should see the guide change: %+v", res)
+	}
+
+	// El destino b nunca recibió la orden: no hereda el snapshot de a
+	res, err = c.Compare(testOrder("G2"), b, testLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.NewOrder {
+		t.Fatal("destination b must not share the snapshot of destination a")
+	}
+
+	if res, _ := c.Compare(testOrder("G2"), other, testLogger); !res.NewOrder {
+		t.Fatal("another tenant must not share the snapshot")
+	}
+}
+
+func TestLegacySnapshotKeyIsRead(t *testing.T) {
+	store := fileStore(t)
+	if err := store.Put(state.LegacySnapshotKey("co", 42), testOrder("G1").Snapshot()); err != nil {
+		t.Fatal(err)
+	}
+	c := NewComparator(nil).WithSnapshots(store, models.DefaultWatchedFields)
+
+	res, err := c.Compare(testOrder("G1"), state.Scope{Country: "co", Destination: "x/y"}, testLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.NewOrder {
+		t.Fatal("order stored under the legacy key must not be reported as new")
+	}
+}
+
+func TestNewOrderWithoutPersistentStore(t *testing.T) {
+	fresh := testOrder("G1")
+	fresh.History = fresh.History[:1]
+	scope := state.Scope{Country: "co", Destination: "x/y"}
+
+	// Sin store o en memoria no se sabe qué se anunció antes de un reinicio
+	for name, c := range map[string]*Comparator{
+		"no store":     NewComparator(nil),
+		"memory store": NewComparator(nil).WithSnapshots(state.NewMemoryStore(), models.DefaultWatchedFields),
+	} {
+		if err := c.MarkSeeded(scope); err != nil {
+			t.Fatal(err)
+		}
+		if res, _ := c.Compare(fresh, scope, testLogger); res.NewOrder {
+			t.Errorf("%s: order must never be new without a persistent store", name)
+		}
+	}
+}
+
+func TestNewOrderFirstRunSeedsSilently(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshots.json")
+	store, err := state.NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewComparator(nil).WithSnapshots(store, models.DefaultWatchedFields)
+	scope := state.Scope{Country: "co", Destination: "x/y"}
+
+	// Primera ejecución: el store está vacío y ninguna orden es nueva
+	existing := testOrder("G1")
+	res, err := c.Compare(existing, scope, testLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.NewOrder {
+		t.Fatal("orders seen on the first run must not be new")
+	}
+	if err := c.Commit(existing, scope); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.MarkSeeded(scope); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Ejecución posterior, tras un reinicio: solo la orden sin snapshot es nueva
+	reloaded, err := state.NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c = NewComparator(nil).WithSnapshots(reloaded, models.DefaultWatchedFields)
+	if res, _ := c.Compare(testOrder("G1"), scope, testLogger); res.NewOrder {
+		t.Fatal("order stored on the first run must not be new")
+	}
+	fresh := testOrder("G1")
+	fresh.ID = 43
+	if res, _ := c.Compare(fresh, scope, testLogger); !res.NewOrder {
+		t.Fatal("unseen order after seeding should be new")
+	}
+
+	// Cada scope se siembra por separado
+	other := state.Scope{Country: "co", Destination: "x/z"}
+	if res, _ := c.Compare(fresh, other, testLogger); res.NewOrder {
+		t.Fatal("a scope that was never seeded must not report new orders")
+	}
+}
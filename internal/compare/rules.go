@@ -0,0 +1,178 @@
+package compare
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/juancollazo-ch/dropi-order-status-service/internal/models"
+)
+
+// TransitionKind clasificación de un cambio de status.
+type TransitionKind string
+
+const (
+	// TransitionNormal avance esperado del flujo logístico
+	TransitionNormal TransitionKind = "normal"
+	// TransitionRegression vuelta atrás sospechosa (ej. ENTREGADO → EN_TRANSITO)
+	TransitionRegression TransitionKind = "regression"
+	// TransitionUnknown transición con algún status que no conocemos
+	TransitionUnknown TransitionKind = "unknown"
+)
+
+// TransitionAction qué hacer con el webhook de una transición.
+type TransitionAction string
+
+const (
+	// ActionDeliver enviar el webhook normalmente
+	ActionDeliver TransitionAction = "deliver"
+	// ActionSuppress no enviar el webhook (queda reportado en el resultado)
+	ActionSuppress TransitionAction = "suppress"
+	// ActionEscalate enviar con prioridad alta y reportar la orden como marcada
+	ActionEscalate TransitionAction = "escalate"
+)
+
+// stages orden del flujo logístico. Un cambio hacia una etapa menor es una
+// regresión salvo que esté permitido explícitamente.
+var stages = map[models.OrderStatus]int{
+	models.StatusPendienteConfirmacion: 0,
+	models.StatusPendiente:             1,
+	models.StatusGuiaGenerada:          2,
+	models.StatusPreparado:             3,
+	models.StatusEnBodega:              4,
+	models.StatusEnTransito:            5,
+	models.StatusEnReparto:             6,
+	models.StatusNovedad:               6,
+	models.StatusNovedadSolucionada:    6,
+	models.StatusEntregado:             7,
+	models.StatusDevolucion:            7,
+	models.StatusDevuelto:              8,
+	models.StatusCancelado:             9,
+	models.StatusRechazado:             9,
+}
+
+// TransitionRules máquina de estados configurable de transiciones válidas.
+type TransitionRules struct {
+	allowed   map[models.OrderStatus]map[models.OrderStatus]bool
+	forbidden map[models.OrderStatus]map[models.OrderStatus]bool
+	actions   map[TransitionKind]TransitionAction
+}
+
+// DefaultTransitionRules avanzar en el flujo es normal, retroceder o salir de
+// un status terminal es regresión (se escala), y lo desconocido se entrega.
+func DefaultTransitionRules() *TransitionRules {
+	r := &TransitionRules{
+		allowed:   make(map[models.OrderStatus]map[models.OrderStatus]bool),
+		forbidden: make(map[models.OrderStatus]map[models.OrderStatus]bool),
+		actions: map[TransitionKind]TransitionAction{
+			TransitionNormal:     ActionDeliver,
+			TransitionRegression: ActionEscalate,
+			TransitionUnknown:    ActionDeliver,
+		},
+	}
+
+	// Una novedad solucionada vuelve a reparto o a tránsito
+	r.Allow(models.StatusNovedad, models.StatusEnTransito)
+	r.Allow(models.StatusNovedadSolucionada, models.StatusEnTransito)
+	r.Allow(models.StatusNovedadSolucionada, models.StatusEnReparto)
+
+	return r
+}
+
+// rulesFile formato de TRANSITION_RULES_FILE:
+//
+//	{
+//	  "allowed":   {"NOVEDAD": ["EN_BODEGA_TRANSPORTADORA"]},
+//	  "forbidden": {"EN_REPARTO": ["NOVEDAD_SOLUCIONADA"]},
+//	  "actions":   {"regression": "suppress", "unknown": "escalate"}
+//	}
+type rulesFile struct {
+	Allowed   map[string][]string `json:"allowed"`
+	Forbidden map[string][]string `json:"forbidden"`
+	Actions   map[string]string   `json:"actions"`
+}
+
+// LoadTransitionRules agrega a las reglas por defecto las del archivo JSON.
+func LoadTransitionRules(path string) (*TransitionRules, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading transition rules: %w", err)
+	}
+
+	var file rulesFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid transition rules JSON: %w", err)
+	}
+
+	r := DefaultTransitionRules()
+	for from, tos := range file.Allowed {
+		for _, to := range tos {
+			r.Allow(models.NormalizeStatus(from), models.NormalizeStatus(to))
+		}
+	}
+	for from, tos := range file.Forbidden {
+		for _, to := range tos {
+			r.Forbid(models.NormalizeStatus(from), models.NormalizeStatus(to))
+		}
+	}
+	for kind, action := range file.Actions {
+		switch k, a := TransitionKind(kind), TransitionAction(action); {
+		case k != TransitionNormal && k != TransitionRegression && k != TransitionUnknown:
+			return nil, fmt.Errorf("unknown transition kind %q", kind)
+		case a != ActionDeliver && a != ActionSuppress && a != ActionEscalate:
+			return nil, fmt.Errorf("unknown transition action %q", action)
+		default:
+			r.actions[k] = a
+		}
+	}
+
+	return r, nil
+}
+
+// Allow marca la transición como normal aunque retroceda en el flujo.
+func (r *TransitionRules) Allow(from, to models.OrderStatus) {
+	setTransition(r.allowed, from, to)
+	delete(r.forbidden[from], to)
+}
+
+// Forbid marca la transición como regresión aunque avance en el flujo.
+func (r *TransitionRules) Forbid(from, to models.OrderStatus) {
+	setTransition(r.forbidden, from, to)
+	delete(r.allowed[from], to)
+}
+
+func setTransition(m map[models.OrderStatus]map[models.OrderStatus]bool, from, to models.OrderStatus) {
+	if m[from] == nil {
+		m[from] = make(map[models.OrderStatus]bool)
+	}
+	m[from][to] = true
+}
+
+// Classify clasifica el cambio from → to.
+func (r *TransitionRules) Classify(from, to models.OrderStatus) TransitionKind {
+	if r.allowed[from][to] {
+		return TransitionNormal
+	}
+	if r.forbidden[from][to] {
+		return TransitionRegression
+	}
+
+	fromStage, fromKnown := stages[from]
+	toStage, toKnown := stages[to]
+	if !fromKnown || !toKnown {
+		return TransitionUnknown
+	}
+
+	if from.IsTerminal() || toStage < fromStage {
+		return TransitionRegression
+	}
+	return TransitionNormal
+}
+
+// ActionFor acción configurada para el tipo de transición.
+func (r *TransitionRules) ActionFor(kind TransitionKind) TransitionAction {
+	if action, ok := r.actions[kind]; ok {
+		return action
+	}
+	return ActionDeliver
+}
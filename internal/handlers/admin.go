@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/juancollazo-ch/dropi-order-status-service/internal/worker"
+	"go.uber.org/zap"
+)
+
+type AdminHandler struct {
+	pool *worker.WorkerPool
+}
+
+func NewAdminHandler(pool *worker.WorkerPool) *AdminHandler {
+	return &AdminHandler{pool: pool}
+}
+
+// WorkersRequest cambia el tamaño del pool y/o activa el autoscaler.
+// Ambos campos son opcionales.
+type WorkersRequest struct {
+	Size      *int  `json:"size,omitempty"`
+	Autoscale *bool `json:"autoscale,omitempty"`
+}
+
+// Workers GET retorna el estado del pool; PUT/POST lo redimensiona.
+// El tamaño se ajusta a los límites WORKER_POOL_MIN / WORKER_POOL_MAX.
+func (h *AdminHandler) Workers(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, h.pool.Stats())
+
+	case http.MethodPut, http.MethodPost:
+		var req WorkersRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if req.Size == nil && req.Autoscale == nil {
+			http.Error(w, "size or autoscale is required", http.StatusBadRequest)
+			return
+		}
+		if req.Size != nil && *req.Size < 1 {
+			http.Error(w, "size must be greater than 0", http.StatusBadRequest)
+			return
+		}
+
+		if req.Autoscale != nil {
+			h.pool.SetAutoscale(*req.Autoscale)
+		}
+		if req.Size != nil {
+			applied := h.pool.Resize(*req.Size)
+			zap.L().Info("Worker pool resized",
+				zap.Int("requested", *req.Size),
+				zap.Int("applied", applied),
+			)
+		}
+
+		writeJSON(w, http.StatusOK, h.pool.Stats())
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/juancollazo-ch/dropi-order-status-service/internal/models"
+	"go.uber.org/zap"
+)
+
+// maxBatchEntries entradas permitidas por batch
+const maxBatchEntries = 50
+
+// ProcessBatch POST /process/batch procesa varias entradas (tenant o key,
+// país, fecha, destino) en paralelo. Todas se validan antes de empezar: una
+// entrada inválida rechaza el batch completo. El header
+// X-Dropi-Integration-Key aplica a las entradas sin tenant_id.
+//
+// Cada entrada tiene su propio deadline (BATCH_ENTRY_TIMEOUT) y el batch
+// completo un máximo (BATCH_TIMEOUT): si las rondas de entradas según la
+// concurrencia no entran en ese máximo, el batch se rechaza y hay que
+// dividirlo.
+func (h *ProcessHandler) ProcessBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// La respuesta se escribe al final: puede superar el WriteTimeout del servidor
+	ctx, cancel := context.WithTimeout(r.Context(), h.batchTimeout)
+	defer cancel()
+	extendWriteDeadline(w, h.batchTimeout+writeDeadlineMargin)
+
+	var batch models.BatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+		zap.L().Error("Invalid JSON", zap.Error(err))
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if len(batch.Entries) == 0 {
+		http.Error(w, "entries are required", http.StatusBadRequest)
+		return
+	}
+	if len(batch.Entries) > maxBatchEntries {
+		http.Error(w, fmt.Sprintf("a batch allows at most %d entries", maxBatchEntries), http.StatusBadRequest)
+		return
+	}
+	if batch.Concurrency < 0 {
+		http.Error(w, "concurrency must be greater than 0", http.StatusBadRequest)
+		return
+	}
+
+	headerKey := integrationKeyFromHeader(r)
+	entries := make([]models.ProcessRequest, 0, len(batch.Entries))
+	for i, entry := range batch.Entries {
+		if entry.Date == "" {
+			entry.Date = batch.Date
+		}
+		key := headerKey
+		if entry.TenantID != "" {
+			key = ""
+		}
+
+		req, status, err := h.prepareRequest(entry, key)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("entry %d: %s", i, err.Error()), status)
+			return
+		}
+		entries = append(entries, req)
+	}
+
+	// Peor caso: cada ronda de entradas en paralelo agota su deadline
+	concurrency := h.svc.BatchConcurrency(batch.Concurrency)
+	rounds := (len(entries) + concurrency - 1) / concurrency
+	if worst := time.Duration(rounds) * h.batchEntryTimeout; worst > h.batchTimeout {
+		http.Error(w, fmt.Sprintf("batch may take up to %s (%d entries, concurrency %d, %s per entry) and the limit is %s: split it into smaller batches",
+			worst, len(entries), concurrency, h.batchEntryTimeout, h.batchTimeout), http.StatusBadRequest)
+		return
+	}
+
+	zap.L().Info("Processing batch",
+		zap.Int("entries", len(entries)),
+		zap.Int("concurrency", concurrency),
+		zap.Duration("entry_timeout", h.batchEntryTimeout),
+	)
+
+	result := h.svc.HandleBatch(ctx, entries, batch.Concurrency, h.batchEntryTimeout)
+
+	w.Header().Set("Content-Type", "application/json")
+
+	switch {
+	case result.Summary.QueueSaturated:
+		// Igual que /process: reintentar más tarde las órdenes rechazadas
+		zap.L().Warn("Webhook queue saturated",
+			zap.Int("webhooks_rejected", result.Summary.WebhooksRejected),
+		)
+		w.Header().Set("Retry-After", strconv.Itoa(queueFullRetryAfterSeconds))
+		w.WriteHeader(http.StatusServiceUnavailable)
+	case result.Summary.Failed == result.Summary.Entries:
+		w.WriteHeader(http.StatusBadGateway)
+	}
+
+	json.NewEncoder(w).Encode(result)
+
+	zap.L().Info("Batch completed",
+		zap.Int("entries", result.Summary.Entries),
+		zap.Int("succeeded", result.Summary.Succeeded),
+		zap.Int("failed", result.Summary.Failed),
+		zap.Int("webhooks_queued", result.Summary.WebhooksQueued),
+		zap.Bool("partial_timeout", result.Summary.PartialTimeout),
+	)
+}
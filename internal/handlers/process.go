@@ -3,7 +3,11 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/juancollazo-ch/dropi-order-status-service/internal/models"
@@ -12,25 +16,113 @@ import (
 	"go.uber.org/zap"
 )
 
+// queueFullRetryAfterSeconds Retry-After sugerido cuando la cola de webhooks está llena
+const queueFullRetryAfterSeconds = 30
+
+// Límites de tiempo de /process y /process/batch. /process responde JSON al
+// final y debe terminar antes del WriteTimeout del servidor (60s); un batch
+// extiende el write deadline de su respuesta hasta su propio límite.
+const (
+	processTimeout           = 45 * time.Second
+	defaultBatchEntryTimeout = 45 * time.Second
+	defaultBatchTimeout      = 5 * time.Minute
+	defaultStreamTimeout     = 10 * time.Minute
+
+	// streamLineTimeout plazo para escribir cada línea NDJSON; se renueva en
+	// cada flush, igual al WriteTimeout del servidor
+	streamLineTimeout = 60 * time.Second
+
+	// writeDeadlineMargin tiempo para escribir la respuesta tras el límite
+	writeDeadlineMargin = 10 * time.Second
+)
+
+// IntegrationKeyHeader header con la integration key de Dropi; también se
+// acepta "Authorization: DropiKey <key>".
+const IntegrationKeyHeader = "X-Dropi-Integration-Key"
+
 type ProcessHandler struct {
 	svc       *service.OrderService
 	validator *validator.RequestValidator
+
+	// allowBodyKey acepta api_key en el body (deprecado); solo con ALLOW_BODY_API_KEY=true
+	allowBodyKey bool
+
+	// batchEntryTimeout deadline de cada entrada de un batch (BATCH_ENTRY_TIMEOUT)
+	batchEntryTimeout time.Duration
+	// batchTimeout duración máxima de un batch (BATCH_TIMEOUT); los que no
+	// entran en ese tiempo se rechazan
+	batchTimeout time.Duration
+	// streamTimeout duración máxima de /process en NDJSON (STREAM_TIMEOUT);
+	// también termina si el cliente se desconecta
+	streamTimeout time.Duration
+	// streamLineTimeout write deadline que se renueva en cada línea
+	streamLineTimeout time.Duration
 }
 
 func NewProcessHandler(svc *service.OrderService) *ProcessHandler {
 	return &ProcessHandler{
-		svc:       svc,
-		validator: validator.NewRequestValidator(),
+		svc:          svc,
+		validator:    validator.NewRequestValidator(),
+		allowBodyKey: os.Getenv("ALLOW_BODY_API_KEY") == "true",
+
+		batchEntryTimeout: durationFromEnv("BATCH_ENTRY_TIMEOUT", defaultBatchEntryTimeout),
+		batchTimeout:      durationFromEnv("BATCH_TIMEOUT", defaultBatchTimeout),
+		streamTimeout:     durationFromEnv("STREAM_TIMEOUT", defaultStreamTimeout),
+		streamLineTimeout: streamLineTimeout,
 	}
 }
 
+// durationFromEnv lee una duración ("45s", "5m"); si falta o es inválida usa def.
+func durationFromEnv(name string, def time.Duration) time.Duration {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		zap.L().Warn("invalid "+name+", using default", zap.String("value", v), zap.Duration("default", def))
+		return def
+	}
+	return d
+}
+
+// extendWriteDeadline permite a esta respuesta superar el WriteTimeout del
+// servidor; los writers que no lo soportan (tests) se ignoran.
+func extendWriteDeadline(w http.ResponseWriter, d time.Duration) {
+	err := http.NewResponseController(w).SetWriteDeadline(time.Now().Add(d))
+	if err != nil && !errors.Is(err, http.ErrNotSupported) {
+		zap.L().Warn("Failed to extend write deadline", zap.Error(err))
+	}
+}
+
+// integrationKeyFromHeader key de X-Dropi-Integration-Key o de
+// "Authorization: DropiKey <key>"; vacío si no viene.
+func integrationKeyFromHeader(r *http.Request) string {
+	if key := strings.TrimSpace(r.Header.Get(IntegrationKeyHeader)); key != "" {
+		return key
+	}
+	const scheme = "DropiKey "
+	authz := r.Header.Get("Authorization")
+	if len(authz) > len(scheme) && strings.EqualFold(authz[:len(scheme)], scheme) {
+		return strings.TrimSpace(authz[len(scheme):])
+	}
+	return ""
+}
+
 func (h *ProcessHandler) ProcessOrders(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(r.Context(), 45*time.Second)
+	// El JSON se escribe al final y debe caber en el WriteTimeout; el stream
+	// escribe a medida que avanza y tiene su propio límite
+	stream := wantsNDJSON(r)
+	timeout := processTimeout
+	if stream {
+		timeout = h.streamTimeout
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
 	defer cancel()
 
 	var req models.ProcessRequest
@@ -40,27 +132,9 @@ func (h *ProcessHandler) ProcessOrders(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Validate required fields
-	if req.APIKey == "" || req.Date == "" {
-		http.Error(w, "api_key and date are required", http.StatusBadRequest)
-		return
-	}
-
-	// Validar formato de fecha (YYYY-MM-DD)
-	if !validator.IsValidDate(req.Date) {
-		zap.L().Error("Invalid date format", zap.String("date", req.Date))
-		http.Error(w, "date must be in format YYYY-MM-DD", http.StatusBadRequest)
-		return
-	}
-
-	// Validar parámetros dinámicos
-	if err := h.validator.ValidateRequest(&req); err != nil {
-		zap.L().Error("Request validation failed",
-			zap.Error(err),
-			zap.String("dropi_country_suffix", req.DropiCountrySuffix),
-			zap.String("webhook_suffix", req.WebhookSuffix),
-		)
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	req, status, err := h.prepareRequest(req, integrationKeyFromHeader(r))
+	if err != nil {
+		http.Error(w, err.Error(), status)
 		return
 	}
 
@@ -68,15 +142,20 @@ func (h *ProcessHandler) ProcessOrders(w http.ResponseWriter, r *http.Request) {
 		zap.String("date", req.Date),
 		zap.String("dropi_country_suffix", req.DropiCountrySuffix),
 		zap.String("webhook_suffix", req.WebhookSuffix),
+		zap.String("webhook_format", string(req.WebhookFormat.OrDefault())),
+		zap.String("tenant_id", req.TenantID),
+		zap.Bool("stream", stream),
 	)
 
-	result, err := h.svc.HandleOrderRequest(
-		ctx,
-		req.APIKey,
-		req.Date,
-		req.DropiCountrySuffix,
-		req.WebhookSuffix,
-	)
+	// Accept: application/x-ndjson → una línea por orden a medida que se procesa
+	if stream {
+		h.streamOrders(ctx, w, func(ctx context.Context, onDetail func(service.OrderStatus)) (*service.ProcessResult, error) {
+			return h.svc.HandleOrderRequestStream(ctx, req, onDetail)
+		})
+		return
+	}
+
+	result, err := h.svc.HandleOrderRequest(ctx, req)
 
 	if err != nil {
 		zap.L().Error("Processing error", zap.Error(err))
@@ -85,6 +164,17 @@ func (h *ProcessHandler) ProcessOrders(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+
+	// Cola saturada: el cliente debe reintentar las órdenes rechazadas más tarde
+	if result.QueueSaturated {
+		zap.L().Warn("Webhook queue saturated",
+			zap.Int("webhooks_rejected", result.WebhooksRejected),
+			zap.Int("queue_depth", result.QueueDepth),
+		)
+		w.Header().Set("Retry-After", strconv.Itoa(queueFullRetryAfterSeconds))
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
 	json.NewEncoder(w).Encode(result)
 
 	zap.L().Info("Process completed successfully",
@@ -94,3 +184,76 @@ func (h *ProcessHandler) ProcessOrders(w http.ResponseWriter, r *http.Request) {
 		zap.Bool("partial_timeout", result.PartialTimeout),
 	)
 }
+
+// prepareRequest toma la integration key (header o body), resuelve tenant_id y
+// valida el request. Retorna el status HTTP a usar si es inválido.
+func (h *ProcessHandler) prepareRequest(req models.ProcessRequest, headerKey string) (models.ProcessRequest, int, error) {
+	// Integration key: header preferido; en el body solo si está permitido
+	switch {
+	case headerKey != "" && req.APIKey != "":
+		return req, http.StatusBadRequest, errors.New("send the integration key either in the header or in the body, not both")
+	case headerKey != "":
+		req.APIKey = models.Secret(headerKey)
+	case req.APIKey != "" && !h.allowBodyKey:
+		return req, http.StatusBadRequest, errors.New("api_key in the body is disabled; use the " + IntegrationKeyHeader + " header")
+	case req.APIKey != "":
+		// Se avisa en cada uso (también por cada entrada de un batch)
+		zap.L().Warn("Deprecated: integration key sent in the JSON body, use the "+IntegrationKeyHeader+" header",
+			zap.String("dropi_country_suffix", req.DropiCountrySuffix),
+			zap.String("webhook_suffix", req.WebhookSuffix),
+		)
+	}
+
+	// tenant_id reemplaza api_key: la key se descifra del registry de tenants
+	if req.TenantID != "" {
+		if req.APIKey != "" {
+			return req, http.StatusBadRequest, errors.New("use either an integration key or tenant_id, not both")
+		}
+		resolved, err := h.svc.ResolveRequest(req)
+		if err != nil {
+			zap.L().Error("Tenant resolution failed", zap.String("tenant_id", req.TenantID), zap.Error(err))
+			return req, tenantErrorStatus(err), err
+		}
+		req = resolved
+	}
+
+	// Validate required fields
+	if req.APIKey == "" || req.Date == "" {
+		return req, http.StatusBadRequest, errors.New("integration key (" + IntegrationKeyHeader + " header or tenant_id) and date are required")
+	}
+
+	// Validar formato de fecha (YYYY-MM-DD)
+	if !validator.IsValidDate(req.Date) {
+		zap.L().Error("Invalid date format", zap.String("date", req.Date))
+		return req, http.StatusBadRequest, errors.New("date must be in format YYYY-MM-DD")
+	}
+
+	// Validar parámetros dinámicos
+	if err := h.validator.ValidateRequest(&req); err != nil {
+		zap.L().Error("Request validation failed",
+			zap.Error(err),
+			zap.String("dropi_country_suffix", req.DropiCountrySuffix),
+			zap.String("webhook_suffix", req.WebhookSuffix),
+		)
+		return req, http.StatusBadRequest, err
+	}
+
+	// Validar formato de entrega del webhook
+	if !req.WebhookFormat.IsValid() {
+		zap.L().Error("Invalid webhook format", zap.String("webhook_format", string(req.WebhookFormat)))
+		return req, http.StatusBadRequest, errors.New("webhook_format must be one of: json, cloudevents-binary, cloudevents-structured")
+	}
+
+	if !req.PayloadVersion.IsValid() {
+		zap.L().Error("Invalid payload version", zap.String("payload_version", string(req.PayloadVersion)))
+		return req, http.StatusBadRequest, errors.New("payload_version must be one of: v1, v2")
+	}
+
+	// Validar filtros de suscripción
+	if err := req.Filter.Validate(); err != nil {
+		zap.L().Error("Invalid webhook filter", zap.Error(err))
+		return req, http.StatusBadRequest, err
+	}
+
+	return req, http.StatusOK, nil
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/juancollazo-ch/dropi-order-status-service/internal/service"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// observeLogs reemplaza el logger global de zap mientras dura el test.
+func observeLogs(t *testing.T) *observer.ObservedLogs {
+	t.Helper()
+	core, logs := observer.New(zapcore.WarnLevel)
+	restore := zap.ReplaceGlobals(zap.New(core))
+	t.Cleanup(restore)
+	return logs
+}
+
+func TestBodyAPIKeyDisabledByDefault(t *testing.T) {
+	t.Setenv("ALLOW_BODY_API_KEY", "")
+	h := NewProcessHandler(nil)
+
+	body := `{"api_key": "secret", "date": "2024-01-10", "dropi_country_suffix": "co", "webhook_suffix": "client/orders"}`
+	rec := httptest.NewRecorder()
+	h.ProcessOrders(rec, httptest.NewRequest(http.MethodPost, "/process", strings.NewReader(body)))
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), IntegrationKeyHeader) {
+		t.Fatalf("body key should be rejected by default, got %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestBodyAPIKeyWarnsOnEveryUse(t *testing.T) {
+	t.Setenv("ALLOW_BODY_API_KEY", "true")
+	h := NewProcessHandler(nil)
+	logs := observeLogs(t)
+
+	// La última entrada tiene la fecha inválida: el batch se rechaza antes de
+	// consultar Dropi, pero las tres entradas usaron la key del body
+	body := `{"date": "2024-01-10", "entries": [
+		{"api_key": "k1", "dropi_country_suffix": "co", "webhook_suffix": "client/a"},
+		{"api_key": "k2", "dropi_country_suffix": "mx", "webhook_suffix": "client/b"},
+		{"api_key": "k3", "dropi_country_suffix": "co", "webhook_suffix": "client/c", "date": "10/01/2024"}
+	]}`
+	rec := httptest.NewRecorder()
+	h.ProcessBatch(rec, httptest.NewRequest(http.MethodPost, "/process/batch", strings.NewReader(body)))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for the invalid entry, got %d", rec.Code)
+	}
+
+	warnings := logs.FilterMessageSnippet("integration key sent in the JSON body").All()
+	if len(warnings) != 3 {
+		t.Fatalf("expected a deprecation warning per entry, got %d", len(warnings))
+	}
+	for _, w := range warnings {
+		for _, f := range w.Context {
+			if strings.HasPrefix(f.String, "k") && len(f.String) == 2 {
+				t.Fatalf("warning must not log the key: %+v", w.Context)
+			}
+		}
+	}
+}
+
+func TestBatchOverBudgetIsRejected(t *testing.T) {
+	t.Setenv("BATCH_CONCURRENCY", "2")
+	t.Setenv("BATCH_ENTRY_TIMEOUT", "45s")
+	t.Setenv("BATCH_TIMEOUT", "1m")
+	h := NewProcessHandler(service.NewOrderService(nil, nil))
+
+	// 3 entradas de a 2: dos rondas de 45s no entran en 1m
+	body := `{"date": "2024-01-10", "entries": [
+		{"dropi_country_suffix": "co", "webhook_suffix": "client/a"},
+		{"dropi_country_suffix": "mx", "webhook_suffix": "client/b"},
+		{"dropi_country_suffix": "cl", "webhook_suffix": "client/c"}
+	]}`
+	r := httptest.NewRequest(http.MethodPost, "/process/batch", strings.NewReader(body))
+	r.Header.Set(IntegrationKeyHeader, "secret")
+	rec := httptest.NewRecorder()
+	h.ProcessBatch(rec, r)
+
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "split it") {
+		t.Fatalf("expected the batch to be rejected as over budget, got %d %q", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/juancollazo-ch/dropi-order-status-service/internal/scheduler"
+)
+
+type SchedulerHandler struct {
+	scheduler *scheduler.Scheduler
+}
+
+func NewSchedulerHandler(s *scheduler.Scheduler) *SchedulerHandler {
+	return &SchedulerHandler{scheduler: s}
+}
+
+// Jobs GET /admin/scheduler lista los tenants programados con su próxima
+// ejecución y las últimas ejecuciones; ?tenant=nombre retorna solo ese job
+// (los del registry se llaman "tenant:país:webhook_suffix").
+func (h *SchedulerHandler) Jobs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if tenant := r.URL.Query().Get("tenant"); tenant != "" {
+		status, ok := h.scheduler.JobStatus(tenant)
+		if !ok {
+			http.Error(w, "tenant not found", http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, status)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, h.scheduler.Status())
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/juancollazo-ch/dropi-order-status-service/internal/models"
+	"github.com/juancollazo-ch/dropi-order-status-service/internal/schema"
+)
+
+// webhookSchemaPath prefijo de los JSON Schema del payload del webhook
+const webhookSchemaPath = "/schemas/webhook/"
+
+type SchemaHandler struct{}
+
+func NewSchemaHandler() *SchemaHandler {
+	return &SchemaHandler{}
+}
+
+// WebhookSchemaURL ruta del JSON Schema de una versión del payload.
+func WebhookSchemaURL(v models.PayloadVersion) string {
+	return webhookSchemaPath + string(v.OrDefault())
+}
+
+// EventSchemaURL ruta del JSON Schema del documento de un evento; para
+// order.status_changed es la misma que WebhookSchemaURL.
+func EventSchemaURL(e models.WebhookEvent, v models.PayloadVersion) string {
+	if e.OrDefault() == models.EventStatusChanged {
+		return WebhookSchemaURL(v)
+	}
+	return webhookSchemaPath + string(e) + "/" + string(v)
+}
+
+// WebhookSchema GET /schemas/webhook/{version} retorna el JSON Schema de la
+// versión del payload de order.status_changed y GET
+// /schemas/webhook/{evento}/{version} el del documento de otro evento
+// (order.created, ...). GET /schemas/webhook/ lista lo publicado.
+func (h *SchemaHandler) WebhookSchema(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, webhookSchemaPath), "/")
+	if path == "" {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"default":  models.DefaultPayloadVersion,
+			"versions": schemaVersions(models.EventStatusChanged),
+			"events":   schemaEventList(),
+		})
+		return
+	}
+
+	event, version := models.EventStatusChanged, path
+	if e, v, ok := strings.Cut(path, "/"); ok {
+		event, version = models.WebhookEvent(e), v
+		if event == models.EventStatusChanged {
+			http.Error(w, fmt.Sprintf("unknown schema %q", path), http.StatusNotFound)
+			return
+		}
+	}
+
+	v := models.PayloadVersion(version)
+	t := event.PayloadType(v)
+	if t == nil {
+		http.Error(w, fmt.Sprintf("unknown payload version %q", path), http.StatusNotFound)
+		return
+	}
+
+	title := "Dropi order webhook " + string(v)
+	if event != models.EventStatusChanged {
+		title = "Dropi " + string(event) + " webhook " + string(v)
+	}
+
+	w.Header().Set("Content-Type", "application/schema+json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(schema.Generate(t, EventSchemaURL(event, v), title))
+}
+
+func schemaVersions(e models.WebhookEvent) []map[string]string {
+	versions := make([]map[string]string, 0, len(e.PayloadVersions()))
+	for _, v := range e.PayloadVersions() {
+		versions = append(versions, map[string]string{
+			"version": string(v),
+			"schema":  EventSchemaURL(e, v),
+		})
+	}
+	return versions
+}
+
+func schemaEventList() []map[string]interface{} {
+	events := make([]map[string]interface{}, 0, len(models.VersionedEvents))
+	for _, e := range models.VersionedEvents {
+		events = append(events, map[string]interface{}{
+			"event":    e,
+			"versions": schemaVersions(e),
+		})
+	}
+	return events
+}
@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/juancollazo-ch/dropi-order-status-service/internal/models"
+	"github.com/juancollazo-ch/dropi-order-status-service/internal/sla"
+)
+
+type SLAHandler struct {
+	monitor *sla.Monitor
+}
+
+func NewSLAHandler(monitor *sla.Monitor) *SLAHandler {
+	return &SLAHandler{monitor: monitor}
+}
+
+// Breaches GET /sla/breaches lista las órdenes que incumplen su SLA. Filtros
+// opcionales por query: country, status, shipping_company.
+func (h *SLAHandler) Breaches(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	filter := sla.Filter{
+		Country:         q.Get("country"),
+		ShippingCompany: q.Get("shipping_company"),
+	}
+	if status := q.Get("status"); status != "" {
+		filter.Status = models.NormalizeStatus(status)
+	}
+
+	breaches := h.monitor.Breaches(filter)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"total":    len(breaches),
+		"breaches": breaches,
+	})
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/juancollazo-ch/dropi-order-status-service/internal/service"
+	"go.uber.org/zap"
+)
+
+// NDJSONContentType respuesta de /process en modo streaming.
+const NDJSONContentType = "application/x-ndjson"
+
+// orderLine línea de una orden: {"type":"order", ...campos de OrderStatus}
+type orderLine struct {
+	Type string `json:"type"`
+	service.OrderStatus
+}
+
+// summaryLine última línea: {"type":"summary", ...campos de ProcessResult}
+type summaryLine struct {
+	Type string `json:"type"`
+	*service.ProcessResult
+	RetryAfterSeconds int `json:"retry_after_seconds,omitempty"` // cola saturada
+}
+
+// errorLine error después de haber empezado a responder.
+type errorLine struct {
+	Type  string `json:"type"`
+	Error string `json:"error"`
+}
+
+// wantsNDJSON true si el cliente pidió Accept: application/x-ndjson.
+func wantsNDJSON(r *http.Request) bool {
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accept))
+		if err == nil && mediaType == NDJSONContentType {
+			return true
+		}
+	}
+	return false
+}
+
+// ndjsonWriter escribe una línea por objeto y hace flush después de cada una.
+// Los headers se envían con la primera línea; si falla la escritura (el
+// cliente se desconectó) las siguientes líneas se descartan. Después de cada
+// flush renueva el write deadline: el WriteTimeout del servidor limita cada
+// línea y no el stream completo.
+type ndjsonWriter struct {
+	w            http.ResponseWriter
+	enc          *json.Encoder
+	rc           *http.ResponseController
+	lineDeadline time.Duration
+	started      bool
+	err          error
+}
+
+func newNDJSONWriter(w http.ResponseWriter, lineDeadline time.Duration) *ndjsonWriter {
+	return &ndjsonWriter{
+		w:            w,
+		enc:          json.NewEncoder(w),
+		rc:           http.NewResponseController(w),
+		lineDeadline: lineDeadline,
+	}
+}
+
+// extendDeadline da a la próxima línea un write deadline completo.
+func (n *ndjsonWriter) extendDeadline() {
+	if n.lineDeadline <= 0 {
+		return
+	}
+	err := n.rc.SetWriteDeadline(time.Now().Add(n.lineDeadline))
+	if err != nil && !errors.Is(err, http.ErrNotSupported) {
+		zap.L().Warn("Failed to extend NDJSON write deadline", zap.Error(err))
+	}
+}
+
+func (n *ndjsonWriter) write(v interface{}) {
+	if n.err != nil {
+		return
+	}
+	if !n.started {
+		n.w.Header().Set("Content-Type", NDJSONContentType)
+		n.w.Header().Set("X-Content-Type-Options", "nosniff")
+		n.w.WriteHeader(http.StatusOK)
+		n.started = true
+	}
+	if n.err = n.enc.Encode(v); n.err != nil {
+		zap.L().Warn("NDJSON stream aborted", zap.Error(n.err))
+		return
+	}
+	if err := n.rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		n.err = err
+		zap.L().Warn("NDJSON stream aborted", zap.Error(n.err))
+		return
+	}
+	n.extendDeadline()
+}
+
+// streamRun procesa el request entregando cada detalle a onDetail.
+type streamRun func(ctx context.Context, onDetail func(service.OrderStatus)) (*service.ProcessResult, error)
+
+// streamOrders responde /process como NDJSON: una línea por orden apenas se
+// compara y al final una línea con el resumen. Como el status HTTP ya se
+// envió, la cola saturada se indica en el resumen (retry_after_seconds).
+func (h *ProcessHandler) streamOrders(ctx context.Context, w http.ResponseWriter, run streamRun) {
+	out := newNDJSONWriter(w, h.streamLineTimeout)
+	// La consulta a Dropi antes de la primera línea también tiene su plazo
+	out.extendDeadline()
+
+	result, err := run(ctx, func(detail service.OrderStatus) {
+		out.write(orderLine{Type: "order", OrderStatus: detail})
+	})
+	if err != nil {
+		zap.L().Error("Processing error", zap.Error(err))
+		if !out.started {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		out.write(errorLine{Type: "error", Error: err.Error()})
+		return
+	}
+
+	summary := summaryLine{Type: "summary", ProcessResult: result}
+	if result.QueueSaturated {
+		zap.L().Warn("Webhook queue saturated",
+			zap.Int("webhooks_rejected", result.WebhooksRejected),
+			zap.Int("queue_depth", result.QueueDepth),
+		)
+		summary.RetryAfterSeconds = queueFullRetryAfterSeconds
+	}
+	out.write(summary)
+
+	zap.L().Info("Process stream completed",
+		zap.Int("orders", result.TotalOrders),
+		zap.Int("changes", result.ChangesDetected),
+		zap.Int("webhooks_queued", result.WebhooksQueued),
+		zap.Bool("partial_timeout", result.PartialTimeout),
+	)
+}
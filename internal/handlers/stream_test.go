@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/juancollazo-ch/dropi-order-status-service/internal/service"
+)
+
+// TestStreamOutlivesServerWriteTimeout el stream dura varias veces el
+// WriteTimeout del servidor (a escala: 100ms en lugar de 60s) y el cliente
+// recibe todas las líneas porque cada flush renueva el write deadline.
+func TestStreamOutlivesServerWriteTimeout(t *testing.T) {
+	const (
+		writeTimeout = 100 * time.Millisecond
+		orders       = 8
+	)
+	h := &ProcessHandler{streamTimeout: 5 * time.Second, streamLineTimeout: writeTimeout}
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), h.streamTimeout)
+		defer cancel()
+		h.streamOrders(ctx, w, func(ctx context.Context, onDetail func(service.OrderStatus)) (*service.ProcessResult, error) {
+			for i := 0; i < orders; i++ {
+				time.Sleep(writeTimeout / 2)
+				onDetail(service.OrderStatus{OrderID: fmt.Sprint(i)})
+			}
+			return &service.ProcessResult{TotalOrders: orders, OrdersProcessed: orders}, nil
+		})
+	}))
+	srv.Config.WriteTimeout = writeTimeout
+	srv.Start()
+	defer srv.Close()
+
+	start := time.Now()
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != NDJSONContentType {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+
+	var types []string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		var line struct {
+			Type string `json:"type"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("invalid line %q: %v", scanner.Text(), err)
+		}
+		types = append(types, line.Type)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("stream cut after %v and %d lines: %v", time.Since(start), len(types), err)
+	}
+
+	if elapsed := time.Since(start); elapsed < 3*writeTimeout {
+		t.Fatalf("stream should outlast the write timeout, took %v", elapsed)
+	}
+	if len(types) != orders+1 || types[orders] != "summary" {
+		t.Fatalf("expected %d order lines and a summary, got %v", orders, types)
+	}
+}
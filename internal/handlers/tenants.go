@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/juancollazo-ch/dropi-order-status-service/internal/tenant"
+	"go.uber.org/zap"
+)
+
+type TenantHandler struct {
+	registry *tenant.Registry
+}
+
+func NewTenantHandler(r *tenant.Registry) *TenantHandler {
+	return &TenantHandler{registry: r}
+}
+
+// Tenants GET /admin/tenants lista los tenants; POST crea uno. La
+// integration_key viaja solo en el request y se guarda cifrada: las
+// respuestas nunca la incluyen.
+func (h *TenantHandler) Tenants(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		tenants := h.registry.List()
+		views := make([]tenant.View, 0, len(tenants))
+		for _, t := range tenants {
+			views = append(views, t.View())
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"total":   len(views),
+			"tenants": views,
+		})
+
+	case http.MethodPost:
+		var in tenant.Input
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		t, err := h.registry.Create(in)
+		if err != nil {
+			zap.L().Error("Tenant create failed", zap.String("tenant_id", in.ID), zap.Error(err))
+			http.Error(w, err.Error(), tenantErrorStatus(err))
+			return
+		}
+		zap.L().Info("Tenant created", zap.String("tenant_id", t.ID))
+		writeJSON(w, http.StatusCreated, t.View())
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// Tenant GET, PUT y DELETE de /admin/tenants/{id}. En PUT los campos omitidos
+// no cambian; integration_key rota la key ("" la elimina).
+func (h *TenantHandler) Tenant(w http.ResponseWriter, r *http.Request) {
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/tenants/"), "/")
+	if id == "" {
+		h.Tenants(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		t, err := h.registry.Get(id)
+		if err != nil {
+			http.Error(w, err.Error(), tenantErrorStatus(err))
+			return
+		}
+		writeJSON(w, http.StatusOK, t.View())
+
+	case http.MethodPut, http.MethodPatch:
+		var in tenant.Input
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		t, err := h.registry.Update(id, in)
+		if err != nil {
+			zap.L().Error("Tenant update failed", zap.String("tenant_id", id), zap.Error(err))
+			http.Error(w, err.Error(), tenantErrorStatus(err))
+			return
+		}
+		zap.L().Info("Tenant updated",
+			zap.String("tenant_id", t.ID),
+			zap.Bool("key_rotated", in.IntegrationKey != nil),
+		)
+		writeJSON(w, http.StatusOK, t.View())
+
+	case http.MethodDelete:
+		if err := h.registry.Delete(id); err != nil {
+			http.Error(w, err.Error(), tenantErrorStatus(err))
+			return
+		}
+		zap.L().Info("Tenant deleted", zap.String("tenant_id", id))
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func tenantErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, tenant.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, tenant.ErrAlreadyExists):
+		return http.StatusConflict
+	case errors.Is(err, tenant.ErrKeyUnavailable), errors.Is(err, tenant.ErrStorage):
+		return http.StatusInternalServerError
+	default:
+		return http.StatusBadRequest
+	}
+}
@@ -0,0 +1,13 @@
+package models
+
+// BatchRequest varias consultas (tenant o key, país, fecha y destino) en una
+// sola llamada a /process/batch.
+type BatchRequest struct {
+	// Date es opcional: fecha por defecto de las entradas que no traen date
+	Date string `json:"date,omitempty"`
+
+	// Concurrency es opcional: entradas procesadas en paralelo, limitada por BATCH_CONCURRENCY
+	Concurrency int `json:"concurrency,omitempty"`
+
+	Entries []ProcessRequest `json:"entries"`
+}
@@ -46,6 +46,18 @@ type DropiOrder struct {
 	Warehouse WarehouseInfo `json:"warehouse"`
 
 	History []HistoryItem `json:"history"`
+
+	// NormalizedStatus status canónico calculado por el servicio (no viene de Dropi)
+	NormalizedStatus OrderStatus `json:"-"`
+
+	// FieldChanges diferencias contra el último snapshot guardado (no viene de Dropi)
+	FieldChanges []FieldChange `json:"-"`
+
+	// Transition último cambio de status, calculado al comparar (no viene de Dropi)
+	Transition *StatusTransition `json:"-"`
+
+	// SLABreach incumplimiento de SLA detectado por el monitor (no viene de Dropi)
+	SLABreach *SLABreach `json:"-"`
 }
 
 type ShopInfo struct {
@@ -134,3 +146,12 @@ func (order *DropiOrder) GetProductNames() []string {
 	}
 	return names
 }
+
+// CanonicalStatus retorna el status normalizado; si el servicio aún no lo
+// calculó usa los alias por defecto.
+func (order *DropiOrder) CanonicalStatus() OrderStatus {
+	if order.NormalizedStatus != "" {
+		return order.NormalizedStatus
+	}
+	return NormalizeStatus(order.Status)
+}
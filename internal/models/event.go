@@ -0,0 +1,114 @@
+package models
+
+import "reflect"
+
+// WebhookEvent tipo de evento que se notifica al receptor.
+type WebhookEvent string
+
+const (
+	// EventStatusChanged cambió el status o algún campo vigilado (comportamiento histórico)
+	EventStatusChanged WebhookEvent = "order.status_changed"
+	// EventOrderCreated la orden se vio por primera vez
+	EventOrderCreated WebhookEvent = "order.created"
+	// EventSLABreached la orden lleva en su status más tiempo del permitido
+	EventSLABreached WebhookEvent = "order.sla_breached"
+)
+
+// OrDefault retorna el evento o EventStatusChanged si está vacío.
+func (e WebhookEvent) OrDefault() WebhookEvent {
+	if e == "" {
+		return EventStatusChanged
+	}
+	return e
+}
+
+// eventPayloadVersions versiones publicadas del documento propio de cada
+// evento, de la más antigua a la más nueva. Se versionan por separado de
+// order.status_changed (PayloadVersions): un v2 del status no cambia el
+// documento de order.created.
+var eventPayloadVersions = map[WebhookEvent][]PayloadVersion{
+	EventOrderCreated: {PayloadV1},
+	EventSLABreached:  {PayloadV1},
+}
+
+// VersionedEvents eventos con documento propio (ver eventPayloadVersions).
+var VersionedEvents = []WebhookEvent{EventOrderCreated, EventSLABreached}
+
+// PayloadVersions versiones publicadas del documento del evento.
+func (e WebhookEvent) PayloadVersions() []PayloadVersion {
+	if versions, ok := eventPayloadVersions[e]; ok {
+		return versions
+	}
+	return PayloadVersions
+}
+
+// PayloadVersion versión del documento que se entrega para el evento. Para
+// order.status_changed es la del destino o request (v); los eventos con
+// documento propio van siempre en su última versión.
+func (e WebhookEvent) PayloadVersion(v PayloadVersion) PayloadVersion {
+	if versions, ok := eventPayloadVersions[e]; ok {
+		return versions[len(versions)-1]
+	}
+	return v.OrDefault()
+}
+
+// PayloadType tipo Go que define el documento del evento en la versión v
+// (para generar el JSON Schema); nil si no existe.
+func (e WebhookEvent) PayloadType(v PayloadVersion) reflect.Type {
+	switch e.OrDefault() {
+	case EventOrderCreated:
+		if v == PayloadV1 {
+			return reflect.TypeOf(NewOrderPayload{})
+		}
+		return nil
+	case EventSLABreached:
+		if v == PayloadV1 {
+			return reflect.TypeOf(SLABreachPayload{})
+		}
+		return nil
+	case EventStatusChanged:
+		return v.PayloadType()
+	}
+	return nil
+}
+
+// NewOrderPayload documento v1 del evento order.created: un resumen de la
+// orden para avisar que entró a Dropi, sin history ni transición.
+type NewOrderPayload struct {
+	Event      WebhookEvent      `json:"event"`
+	ID         int64             `json:"id"`
+	Type       string            `json:"type"`
+	CreatedAt  string            `json:"created_at"`
+	Status     WebhookStatusV2   `json:"status"`
+	Total      *Money            `json:"total"`
+	TotalOrder string            `json:"total_order"`
+	Customer   WebhookCustomerV2 `json:"customer"`
+	Address    WebhookAddressV2  `json:"address"`
+	Shop       WebhookShopV2     `json:"shop"`
+	Items      []WebhookItemV2   `json:"items"`
+}
+
+// ToNewOrderPayload documento order.created; el total se interpreta en la
+// moneda del país (null si no se pudo).
+func (order DropiOrder) ToNewOrderPayload(countrySuffix string) NewOrderPayload {
+	v2 := order.ToWebhookPayloadV2(PayloadOptions{})
+
+	var total *Money
+	if m, err := order.Total(countrySuffix); err == nil {
+		total = &m
+	}
+
+	return NewOrderPayload{
+		Event:      EventOrderCreated,
+		ID:         order.ID,
+		Type:       order.Type,
+		CreatedAt:  order.CreatedAt,
+		Status:     v2.Status,
+		Total:      total,
+		TotalOrder: order.TotalOrder,
+		Customer:   v2.Customer,
+		Address:    v2.Address,
+		Shop:       v2.Shop,
+		Items:      v2.Items,
+	}
+}
@@ -0,0 +1,148 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// WebhookFilter decide qué cambios de status disparan un webhook. Sin filtro
+// se envían todos. Un cambio se envía si coincide con Include (cuando existe)
+// y no coincide con Exclude.
+type WebhookFilter struct {
+	Include *FilterRule `json:"include,omitempty"`
+	Exclude *FilterRule `json:"exclude,omitempty"`
+}
+
+// FilterRule criterios de un filtro. Cada lista vacía se ignora; dentro de una
+// lista basta con que coincida un valor y entre listas deben coincidir todas.
+type FilterRule struct {
+	// Statuses status nuevo (se normaliza: "EN TRÁNSITO" = "EN_TRANSITO")
+	Statuses []string `json:"statuses,omitempty"`
+	// Transitions "ANTERIOR->NUEVO"; "*" sirve de comodín: "*->NOVEDAD"
+	Transitions       []string `json:"transitions,omitempty"`
+	ShippingCompanies []string `json:"shipping_companies,omitempty"`
+	ShopIDs           []int64  `json:"shop_ids,omitempty"`
+	WarehouseIDs      []int64  `json:"warehouse_ids,omitempty"`
+	OrderTypes        []string `json:"order_types,omitempty"`
+}
+
+// Validate revisa el formato de las transiciones.
+func (f *WebhookFilter) Validate() error {
+	if f == nil {
+		return nil
+	}
+	for _, rule := range []*FilterRule{f.Include, f.Exclude} {
+		if rule == nil {
+			continue
+		}
+		for _, t := range rule.Transitions {
+			if _, _, ok := splitTransition(t); !ok {
+				return fmt.Errorf("invalid filter transition %q, expected FROM->TO", t)
+			}
+		}
+	}
+	return nil
+}
+
+// Allows indica si el cambio from → to de la orden debe notificarse. Los
+// status del filtro se normalizan con n y los alias del país (from y to ya
+// vienen normalizados así); con n nil se usan los alias por defecto.
+func (f *WebhookFilter) Allows(n *StatusNormalizer, country string, order *DropiOrder, from, to OrderStatus) bool {
+	if f == nil {
+		return true
+	}
+	if n == nil {
+		n = defaultNormalizer
+	}
+	normalize := func(raw string) OrderStatus { return n.Normalize(raw, country) }
+
+	if f.Include != nil && !f.Include.matches(normalize, order, from, to) {
+		return false
+	}
+	if f.Exclude != nil && f.Exclude.matches(normalize, order, from, to) {
+		return false
+	}
+	return true
+}
+
+// matches todos los criterios no vacíos deben coincidir.
+func (r *FilterRule) matches(normalize func(string) OrderStatus, order *DropiOrder, from, to OrderStatus) bool {
+	if len(r.Statuses) > 0 && !containsStatus(normalize, r.Statuses, to) {
+		return false
+	}
+	if len(r.Transitions) > 0 && !matchesTransition(normalize, r.Transitions, from, to) {
+		return false
+	}
+	if len(r.ShippingCompanies) > 0 && !containsFold(r.ShippingCompanies, order.ShippingCompany) {
+		return false
+	}
+	if len(r.ShopIDs) > 0 && !containsID(r.ShopIDs, order.ShopID) {
+		return false
+	}
+	if len(r.WarehouseIDs) > 0 && !containsID(r.WarehouseIDs, warehouseID(order)) {
+		return false
+	}
+	if len(r.OrderTypes) > 0 && !containsFold(r.OrderTypes, order.Type) {
+		return false
+	}
+	return true
+}
+
+func warehouseID(order *DropiOrder) int64 {
+	if order.WarehouseID != 0 {
+		return order.WarehouseID
+	}
+	if order.Warehouse.ID != nil {
+		return *order.Warehouse.ID
+	}
+	return 0
+}
+
+func splitTransition(t string) (string, string, bool) {
+	parts := strings.Split(t, "->")
+	if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+		return "", "", false
+	}
+	return strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]), true
+}
+
+func matchesTransition(normalize func(string) OrderStatus, transitions []string, from, to OrderStatus) bool {
+	for _, t := range transitions {
+		f, d, ok := splitTransition(t)
+		if !ok {
+			continue
+		}
+		if (f == "*" || normalize(f) == from) && (d == "*" || normalize(d) == to) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsStatus(normalize func(string) OrderStatus, statuses []string, status OrderStatus) bool {
+	for _, s := range statuses {
+		if normalize(s) == status {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(values []string, v string) bool {
+	v = strings.TrimSpace(v)
+	for _, candidate := range values {
+		if strings.EqualFold(strings.TrimSpace(candidate), v) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsID(ids []int64, id int64) bool {
+	for _, candidate := range ids {
+		if candidate == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,53 @@
+package models
+
+import "testing"
+
+func TestFilterUsesCountryAliases(t *testing.T) {
+	n := NewStatusNormalizer()
+	n.AddAlias("ec", "ENTREGADO A CLIENTE", StatusEntregado)
+
+	f := &WebhookFilter{Include: &FilterRule{
+		Statuses:    []string{"ENTREGADO A CLIENTE"},
+		Transitions: []string{"*->ENTREGADO A CLIENTE"},
+	}}
+	order := &DropiOrder{}
+
+	if !f.Allows(n, "ec", order, StatusEnTransito, StatusEntregado) {
+		t.Fatal("country alias in filter should match the canonical status")
+	}
+	if f.Allows(n, "co", order, StatusEnTransito, StatusEntregado) {
+		t.Fatal("alias of another country must not match")
+	}
+}
+
+func TestFilterIncludeExclude(t *testing.T) {
+	f := &WebhookFilter{
+		Include: &FilterRule{ShippingCompanies: []string{"servientrega"}},
+		Exclude: &FilterRule{Transitions: []string{"GUIA GENERADA->*"}},
+	}
+	order := &DropiOrder{ShippingCompany: "SERVIENTREGA"}
+
+	tests := []struct {
+		name     string
+		from, to OrderStatus
+		want     bool
+	}{
+		{"included", StatusEnTransito, StatusEntregado, true},
+		{"excluded transition", StatusGuiaGenerada, StatusEnTransito, false},
+	}
+	for _, tt := range tests {
+		if got := f.Allows(nil, "", order, tt.from, tt.to); got != tt.want {
+			t.Errorf("%s: Allows = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	other := &DropiOrder{ShippingCompany: "Coordinadora"}
+	if f.Allows(nil, "", other, StatusEnTransito, StatusEntregado) {
+		t.Error("order outside include rule should be filtered")
+	}
+
+	var none *WebhookFilter
+	if !none.Allows(nil, "", other, "", StatusEntregado) {
+		t.Error("nil filter must allow everything")
+	}
+}
@@ -0,0 +1,34 @@
+package models
+
+// WebhookFormat indica cómo se serializa el webhook hacia un destino.
+type WebhookFormat string
+
+const (
+	// WebhookFormatJSON es el documento JSON propio del servicio (comportamiento histórico)
+	WebhookFormatJSON WebhookFormat = "json"
+
+	// WebhookFormatCloudEventsBinary envía los atributos CloudEvents como headers ce-*
+	// y el payload como body
+	WebhookFormatCloudEventsBinary WebhookFormat = "cloudevents-binary"
+
+	// WebhookFormatCloudEventsStructured envía el evento completo como
+	// application/cloudevents+json
+	WebhookFormatCloudEventsStructured WebhookFormat = "cloudevents-structured"
+)
+
+// IsValid indica si el formato es soportado. El valor vacío equivale a JSON.
+func (f WebhookFormat) IsValid() bool {
+	switch f {
+	case "", WebhookFormatJSON, WebhookFormatCloudEventsBinary, WebhookFormatCloudEventsStructured:
+		return true
+	}
+	return false
+}
+
+// OrDefault retorna JSON cuando el formato no fue especificado.
+func (f WebhookFormat) OrDefault() WebhookFormat {
+	if f == "" {
+		return WebhookFormatJSON
+	}
+	return f
+}
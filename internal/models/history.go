@@ -0,0 +1,103 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Tipos de anomalías del history.
+const (
+	// AnomalyMissingTimestamp item sin created_at interpretable; se ubica
+	// después del item anterior en el orden recibido
+	AnomalyMissingTimestamp = "missing_timestamp"
+	// AnomalyOutOfOrder Dropi envió el history desordenado
+	AnomalyOutOfOrder = "out_of_order"
+	// AnomalyOutOfOrderID un id menor aparece después de uno mayor en orden cronológico
+	AnomalyOutOfOrderID = "out_of_order_id"
+	// AnomalyDuplicate item repetido (mismo id, o mismo status y fecha); se descarta
+	AnomalyDuplicate = "duplicate"
+)
+
+// HistoryAnomaly problema encontrado al normalizar el history.
+type HistoryAnomaly struct {
+	Kind      string `json:"kind"`
+	HistoryID int64  `json:"history_id"`
+	Detail    string `json:"detail,omitempty"`
+}
+
+// NormalizeHistory ordena el history por created_at y luego por id, descarta
+// duplicados y reporta lo que encontró. No modifica items.
+func NormalizeHistory(items []HistoryItem) ([]HistoryItem, []HistoryAnomaly) {
+	var anomalies []HistoryAnomaly
+
+	type entry struct {
+		item HistoryItem
+		at   time.Time
+	}
+
+	entries := make([]entry, len(items))
+	var last time.Time
+	for i, item := range items {
+		at, err := item.CreatedTime()
+		if err != nil {
+			anomalies = append(anomalies, HistoryAnomaly{
+				Kind:      AnomalyMissingTimestamp,
+				HistoryID: item.ID,
+				Detail:    fmt.Sprintf("created_at %q", item.CreatedAt),
+			})
+			at = last
+		}
+		last = at
+		entries[i] = entry{item: item, at: at}
+	}
+
+	less := func(a, b entry) bool {
+		if !a.at.Equal(b.at) {
+			return a.at.Before(b.at)
+		}
+		return a.item.ID < b.item.ID
+	}
+
+	if !sort.SliceIsSorted(entries, func(i, j int) bool { return less(entries[i], entries[j]) }) {
+		anomalies = append(anomalies, HistoryAnomaly{Kind: AnomalyOutOfOrder})
+		sort.SliceStable(entries, func(i, j int) bool { return less(entries[i], entries[j]) })
+	}
+
+	out := make([]HistoryItem, 0, len(entries))
+	seenIDs := make(map[int64]bool, len(entries))
+	var maxID int64
+	for i, e := range entries {
+		if e.item.ID != 0 && seenIDs[e.item.ID] {
+			anomalies = append(anomalies, HistoryAnomaly{Kind: AnomalyDuplicate, HistoryID: e.item.ID})
+			continue
+		}
+		if i > 0 {
+			prev := entries[i-1]
+			if (e.item.ID == 0 || prev.item.ID == 0) && prev.item.Status == e.item.Status && prev.at.Equal(e.at) {
+				anomalies = append(anomalies, HistoryAnomaly{
+					Kind:      AnomalyDuplicate,
+					HistoryID: e.item.ID,
+					Detail:    fmt.Sprintf("status %q at %s", e.item.Status, e.item.CreatedAt),
+				})
+				continue
+			}
+		}
+
+		if e.item.ID != 0 {
+			if e.item.ID < maxID {
+				anomalies = append(anomalies, HistoryAnomaly{
+					Kind:      AnomalyOutOfOrderID,
+					HistoryID: e.item.ID,
+					Detail:    fmt.Sprintf("after id %d", maxID),
+				})
+			} else {
+				maxID = e.item.ID
+			}
+			seenIDs[e.item.ID] = true
+		}
+		out = append(out, e.item)
+	}
+
+	return out, anomalies
+}
@@ -0,0 +1,126 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func ids(items []HistoryItem) []int64 {
+	out := make([]int64, len(items))
+	for i, item := range items {
+		out[i] = item.ID
+	}
+	return out
+}
+
+func kinds(anomalies []HistoryAnomaly) []string {
+	out := make([]string, len(anomalies))
+	for i, a := range anomalies {
+		out[i] = a.Kind
+	}
+	return out
+}
+
+func TestNormalizeHistory(t *testing.T) {
+	tests := []struct {
+		name      string
+		items     []HistoryItem
+		wantIDs   []int64
+		wantKinds []string
+	}{
+		{
+			name: "already sorted",
+			items: []HistoryItem{
+				{ID: 1, Status: "GUIA_GENERADA", CreatedAt: "2024-05-01 10:00:00"},
+				{ID: 2, Status: "EN TRANSITO", CreatedAt: "2024-05-02 10:00:00"},
+			},
+			wantIDs:   []int64{1, 2},
+			wantKinds: []string{},
+		},
+		{
+			name: "out of order",
+			items: []HistoryItem{
+				{ID: 2, Status: "EN TRANSITO", CreatedAt: "2024-05-02 10:00:00"},
+				{ID: 1, Status: "GUIA_GENERADA", CreatedAt: "2024-05-01 10:00:00"},
+			},
+			wantIDs:   []int64{1, 2},
+			wantKinds: []string{AnomalyOutOfOrder},
+		},
+		{
+			name: "same timestamp sorted by id",
+			items: []HistoryItem{
+				{ID: 5, Status: "EN TRANSITO", CreatedAt: "2024-05-02 10:00:00"},
+				{ID: 4, Status: "GUIA_GENERADA", CreatedAt: "2024-05-02 10:00:00"},
+			},
+			wantIDs:   []int64{4, 5},
+			wantKinds: []string{AnomalyOutOfOrder},
+		},
+		{
+			name: "duplicate id",
+			items: []HistoryItem{
+				{ID: 1, Status: "GUIA_GENERADA", CreatedAt: "2024-05-01 10:00:00"},
+				{ID: 1, Status: "GUIA_GENERADA", CreatedAt: "2024-05-01 10:00:00"},
+				{ID: 2, Status: "EN TRANSITO", CreatedAt: "2024-05-02 10:00:00"},
+			},
+			wantIDs:   []int64{1, 2},
+			wantKinds: []string{AnomalyDuplicate},
+		},
+		{
+			name: "duplicate without id",
+			items: []HistoryItem{
+				{Status: "GUIA_GENERADA", CreatedAt: "2024-05-01 10:00:00"},
+				{Status: "GUIA_GENERADA", CreatedAt: "2024-05-01 10:00:00"},
+			},
+			wantIDs:   []int64{0},
+			wantKinds: []string{AnomalyDuplicate},
+		},
+		{
+			name: "id lower than previous in time",
+			items: []HistoryItem{
+				{ID: 9, Status: "GUIA_GENERADA", CreatedAt: "2024-05-01 10:00:00"},
+				{ID: 3, Status: "EN TRANSITO", CreatedAt: "2024-05-02 10:00:00"},
+			},
+			wantIDs:   []int64{9, 3},
+			wantKinds: []string{AnomalyOutOfOrderID},
+		},
+		{
+			name: "missing timestamp keeps received position",
+			items: []HistoryItem{
+				{ID: 1, Status: "GUIA_GENERADA", CreatedAt: "2024-05-01 10:00:00"},
+				{ID: 2, Status: "EN BODEGA", CreatedAt: "no date"},
+				{ID: 3, Status: "EN TRANSITO", CreatedAt: "2024-05-02 10:00:00"},
+			},
+			wantIDs:   []int64{1, 2, 3},
+			wantKinds: []string{AnomalyMissingTimestamp},
+		},
+		{
+			name:      "empty",
+			items:     nil,
+			wantIDs:   []int64{},
+			wantKinds: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, anomalies := NormalizeHistory(tt.items)
+			if !reflect.DeepEqual(ids(got), tt.wantIDs) {
+				t.Errorf("ids = %v, want %v", ids(got), tt.wantIDs)
+			}
+			if !reflect.DeepEqual(kinds(anomalies), tt.wantKinds) {
+				t.Errorf("anomalies = %v, want %v", kinds(anomalies), tt.wantKinds)
+			}
+		})
+	}
+}
+
+func TestNormalizeHistoryDoesNotModifyInput(t *testing.T) {
+	items := []HistoryItem{
+		{ID: 2, Status: "EN TRANSITO", CreatedAt: "2024-05-02 10:00:00"},
+		{ID: 1, Status: "GUIA_GENERADA", CreatedAt: "2024-05-01 10:00:00"},
+	}
+	NormalizeHistory(items)
+	if items[0].ID != 2 || items[1].ID != 1 {
+		t.Fatalf("input slice was reordered: %v", ids(items))
+	}
+}
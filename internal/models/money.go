@@ -0,0 +1,159 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// currencyByCountry moneda de cada dropi_country_suffix.
+var currencyByCountry = map[string]string{
+	"co":     "COP",
+	"mx":     "MXN",
+	"cl":     "CLP",
+	"ar":     "ARS",
+	"ec":     "USD",
+	"gt":     "GTQ",
+	"pa":     "USD",
+	"py.com": "PYG",
+	"pe":     "PEN",
+}
+
+// zeroDecimalCurrencies monedas que en la práctica no usan decimales; en ellas
+// "85.000" se lee como ochenta y cinco mil.
+var zeroDecimalCurrencies = map[string]bool{
+	"COP": true,
+	"CLP": true,
+	"PYG": true,
+}
+
+// CurrencyForCountry moneda del país; "" si no se conoce.
+func CurrencyForCountry(countrySuffix string) string {
+	return currencyByCountry[strings.ToLower(strings.TrimSpace(countrySuffix))]
+}
+
+// Money monto decimal exacto en centésimas (sin errores de float).
+type Money struct {
+	Cents    int64  `json:"cents"`
+	Currency string `json:"currency"`
+}
+
+// ErrCurrencyMismatch suma de montos en monedas distintas.
+var ErrCurrencyMismatch = errors.New("currency mismatch")
+
+// ParseMoney interpreta los montos que envía Dropi ("85000.00", "85000",
+// "$ 85.000", "1.234,50"). Con ambos separadores el último es el decimal; con
+// uno solo repetido es de miles, y "85.000" es de miles solo en monedas sin
+// decimales (COP, CLP, PYG) y si la parte entera tiene a lo sumo 3 dígitos.
+func ParseMoney(raw, currency string) (Money, error) {
+	s := strings.TrimSpace(raw)
+	s = strings.TrimPrefix(s, currency)
+	s = strings.NewReplacer("$", "", " ", "", "\u00a0", "").Replace(s)
+	if s == "" {
+		return Money{}, fmt.Errorf("empty amount")
+	}
+
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+
+	intPart, fracPart, err := splitDecimal(s, zeroDecimalCurrencies[currency])
+	if err != nil {
+		return Money{}, fmt.Errorf("invalid amount %q: %w", raw, err)
+	}
+
+	units, err := strconv.ParseInt(intPart, 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("invalid amount %q", raw)
+	}
+
+	// Se redondea a centésimas (half up)
+	cents := units * 100
+	if fracPart != "" {
+		frac := (fracPart + "000")[:3]
+		n, err := strconv.Atoi(frac)
+		if err != nil {
+			return Money{}, fmt.Errorf("invalid amount %q", raw)
+		}
+		cents += int64((n + 5) / 10)
+	}
+	if negative {
+		cents = -cents
+	}
+
+	return Money{Cents: cents, Currency: currency}, nil
+}
+
+// splitDecimal separa parte entera y decimal quitando separadores de miles.
+func splitDecimal(s string, zeroDecimals bool) (string, string, error) {
+	lastDot := strings.LastIndex(s, ".")
+	lastComma := strings.LastIndex(s, ",")
+
+	decimalSep := ""
+	switch {
+	case lastDot >= 0 && lastComma >= 0:
+		decimalSep = "."
+		if lastComma > lastDot {
+			decimalSep = ","
+		}
+	case lastDot >= 0 || lastComma >= 0:
+		sep, idx := ".", lastDot
+		if lastComma >= 0 {
+			sep, idx = ",", lastComma
+		}
+		repeated := strings.Count(s, sep) > 1
+		// Un solo separador es de miles solo si le sigue un grupo de 3 dígitos
+		// y lo precede un grupo de 1 a 3 ("85.000"); "85000.000" es decimal
+		head := s[:idx]
+		thousands := zeroDecimals && len(s)-idx-1 == 3 &&
+			len(head) >= 1 && len(head) <= 3 && head[0] != '0'
+		if !repeated && !thousands {
+			decimalSep = sep
+		}
+	}
+
+	intPart, fracPart := s, ""
+	if decimalSep != "" {
+		idx := strings.LastIndex(s, decimalSep)
+		intPart, fracPart = s[:idx], s[idx+1:]
+	}
+	intPart = strings.NewReplacer(".", "", ",", "").Replace(intPart)
+	if intPart == "" {
+		intPart = "0"
+	}
+
+	for _, part := range []string{intPart, fracPart} {
+		for _, r := range part {
+			if r < '0' || r > '9' {
+				return "", "", fmt.Errorf("unexpected character %q", r)
+			}
+		}
+	}
+	return intPart, fracPart, nil
+}
+
+// Add suma dos montos de la misma moneda.
+func (m Money) Add(other Money) (Money, error) {
+	if m.Currency != other.Currency && m.Currency != "" && other.Currency != "" {
+		return m, fmt.Errorf("%w: %s + %s", ErrCurrencyMismatch, m.Currency, other.Currency)
+	}
+	currency := m.Currency
+	if currency == "" {
+		currency = other.Currency
+	}
+	return Money{Cents: m.Cents + other.Cents, Currency: currency}, nil
+}
+
+// String monto con dos decimales, ej. "85000.00 COP".
+func (m Money) String() string {
+	sign := ""
+	cents := m.Cents
+	if cents < 0 {
+		sign, cents = "-", -cents
+	}
+	s := fmt.Sprintf("%s%d.%02d", sign, cents/100, cents%100)
+	if m.Currency != "" {
+		s += " " + m.Currency
+	}
+	return s
+}
@@ -0,0 +1,74 @@
+package models
+
+import "testing"
+
+func TestSplitDecimal(t *testing.T) {
+	tests := []struct {
+		in           string
+		zeroDecimals bool
+		intPart      string
+		fracPart     string
+	}{
+		{"85000", true, "85000", ""},
+		{"85000.00", true, "85000", "00"},
+		{"85000.000", true, "85000", "000"},
+		{"85.000", true, "85000", ""},
+		{"85,000", true, "85000", ""},
+		{"850.000", true, "850000", ""},
+		{"1.234.567", true, "1234567", ""},
+		{"1.234,50", true, "1234", "50"},
+		{"1,234.50", true, "1234", "50"},
+		{"0.500", true, "0", "500"},
+		{".500", true, "0", "500"},
+		{"85.000", false, "85", "000"},
+		{"85000.5", false, "85000", "5"},
+		{"1,5", false, "1", "5"},
+		{"1.234.567", false, "1234567", ""},
+	}
+	for _, tt := range tests {
+		intPart, fracPart, err := splitDecimal(tt.in, tt.zeroDecimals)
+		if err != nil {
+			t.Errorf("splitDecimal(%q, %v) error: %v", tt.in, tt.zeroDecimals, err)
+			continue
+		}
+		if intPart != tt.intPart || fracPart != tt.fracPart {
+			t.Errorf("splitDecimal(%q, %v) = (%q, %q), want (%q, %q)",
+				tt.in, tt.zeroDecimals, intPart, fracPart, tt.intPart, tt.fracPart)
+		}
+	}
+}
+
+func TestSplitDecimalRejectsGarbage(t *testing.T) {
+	for _, in := range []string{"85a00", "12.3x", "1e5"} {
+		if _, _, err := splitDecimal(in, true); err == nil {
+			t.Errorf("splitDecimal(%q) should fail", in)
+		}
+	}
+}
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		raw      string
+		currency string
+		cents    int64
+	}{
+		{"85000.00", "COP", 8500000},
+		{"85000.000", "COP", 8500000},
+		{"$ 85.000", "COP", 8500000},
+		{"COP 1.234.567", "COP", 123456700},
+		{"1.234,50", "CLP", 123450},
+		{"85.000", "MXN", 8500},
+		{"19.995", "USD", 2000},
+		{"-1,5", "PEN", -150},
+	}
+	for _, tt := range tests {
+		m, err := ParseMoney(tt.raw, tt.currency)
+		if err != nil {
+			t.Errorf("ParseMoney(%q, %s) error: %v", tt.raw, tt.currency, err)
+			continue
+		}
+		if m.Cents != tt.cents || m.Currency != tt.currency {
+			t.Errorf("ParseMoney(%q, %s) = %v, want %d cents", tt.raw, tt.currency, m, tt.cents)
+		}
+	}
+}
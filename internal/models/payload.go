@@ -1,6 +1,9 @@
 package models
 
-// WebhookPayload estructura simplificada para el webhook
+// WebhookPayload estructura simplificada para el webhook. Es el contrato v1 y
+// conserva la forma original: todo campo nuevo va solo en v2 (status_code,
+// status_label, status_terminal, changes) y los bloques opt-in de
+// PayloadOptions (transition, history) también; v1 los ignora.
 type WebhookPayload struct {
     ID                  int64         `json:"id"`
     Status              string        `json:"status"`
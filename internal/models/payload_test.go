@@ -0,0 +1,268 @@
+package models_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/juancollazo-ch/dropi-order-status-service/internal/models"
+	"github.com/juancollazo-ch/dropi-order-status-service/internal/schema"
+)
+
+var update = flag.Bool("update", false, "reescribe los archivos golden de testdata")
+
+func loadOrder(t *testing.T) models.DropiOrder {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "order.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var order models.DropiOrder
+	if err := json.Unmarshal(data, &order); err != nil {
+		t.Fatal(err)
+	}
+	order.FieldChanges = []models.FieldChange{{Field: models.FieldShippingGuide, Old: "", New: order.ShippingGuide}}
+	return order
+}
+
+// assertGolden compara got con testdata/name; con -update lo reescribe.
+func assertGolden(t *testing.T, name string, got interface{}) []byte {
+	t.Helper()
+	data, err := json.MarshalIndent(got, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = append(data, '\n')
+
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("missing golden %s (run go test -update): %v", path, err)
+	}
+	if !bytes.Equal(data, want) {
+		t.Errorf("%s changed: a published payload version must not change shape; add a new version instead.\ngot:\n%s", path, data)
+	}
+	return data
+}
+
+// contract documento publicado: su tipo (schema) y cómo se construye.
+type contract struct {
+	name    string
+	typ     reflect.Type
+	payload func(models.DropiOrder, models.PayloadOptions) interface{}
+}
+
+// contracts un documento por versión de order.status_changed y por versión
+// de cada evento con documento propio.
+func contracts(t *testing.T) []contract {
+	var out []contract
+	for _, v := range models.PayloadVersions {
+		v := v
+		out = append(out, contract{
+			name: string(v),
+			typ:  v.PayloadType(),
+			payload: func(o models.DropiOrder, opts models.PayloadOptions) interface{} {
+				return o.ToVersionedPayload(v, opts)
+			},
+		})
+	}
+	for _, e := range models.VersionedEvents {
+		for _, v := range e.PayloadVersions() {
+			c := contract{name: string(e) + "_" + string(v), typ: e.PayloadType(v)}
+			switch e {
+			case models.EventOrderCreated:
+				c.payload = func(o models.DropiOrder, _ models.PayloadOptions) interface{} {
+					return o.ToNewOrderPayload("co")
+				}
+			case models.EventSLABreached:
+				c.payload = func(o models.DropiOrder, _ models.PayloadOptions) interface{} {
+					o.SLABreach = slaBreach(o)
+					return o.ToSLABreachPayload()
+				}
+			default:
+				t.Fatalf("no payload builder for event %s", e)
+			}
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// slaBreach incumplimiento fijo para el golden de order.sla_breached.
+func slaBreach(o models.DropiOrder) *models.SLABreach {
+	since := time.Date(2024, 1, 10, 8, 0, 0, 0, time.UTC)
+	return &models.SLABreach{
+		OrderID:   o.ID,
+		Country:   "co",
+		Status:    models.StatusGuiaGenerada,
+		Since:     since,
+		Threshold: models.Duration(72 * time.Hour),
+		InStatus:  models.Duration(80 * time.Hour),
+	}
+}
+
+func TestPayloadGolden(t *testing.T) {
+	order := loadOrder(t)
+	for _, c := range contracts(t) {
+		assertGolden(t, fmt.Sprintf("payload_%s.golden.json", c.name), c.payload(order, models.PayloadOptions{}))
+	}
+}
+
+func TestPayloadMatchesSchema(t *testing.T) {
+	order := loadOrder(t)
+	for _, c := range contracts(t) {
+		if c.typ == nil {
+			t.Fatalf("%s: no payload type", c.name)
+		}
+		raw := assertGolden(t, fmt.Sprintf("schema_%s.golden.json", c.name), schema.Generate(c.typ, "", ""))
+
+		// Se valida contra el schema publicado (JSON), no contra el map en memoria
+		var published map[string]interface{}
+		if err := json.Unmarshal(raw, &published); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, opts := range []models.PayloadOptions{{}, {Transition: true, History: true}} {
+			data, err := json.Marshal(c.payload(order, opts))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var doc interface{}
+			if err := json.Unmarshal(data, &doc); err != nil {
+				t.Fatal(err)
+			}
+			if errs := validate(doc, published, "$"); len(errs) > 0 {
+				t.Errorf("%s payload (%+v) does not match its schema:\n%v", c.name, opts, errs)
+			}
+		}
+	}
+}
+
+func TestPayloadV1BaselineFields(t *testing.T) {
+	// Todo campo nuevo es solo v2, también los bloques opt-in
+	order := loadOrder(t)
+	order.History = []models.HistoryItem{{ID: 1, Status: "PENDIENTE"}, {ID: 2, Status: "EN TRÁNSITO"}}
+	order.Transition = models.NewStatusTransition(order.History[0], order.History[1], models.StatusPendiente, models.StatusEnTransito)
+
+	for _, opts := range []models.PayloadOptions{{}, {Transition: true, History: true}} {
+		data, err := json.Marshal(order.ToVersionedPayload(models.PayloadV1, opts))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var doc map[string]interface{}
+		if err := json.Unmarshal(data, &doc); err != nil {
+			t.Fatal(err)
+		}
+		for _, field := range []string{"status_code", "status_label", "status_terminal", "changes", "transition", "history"} {
+			if _, ok := doc[field]; ok {
+				t.Errorf("v1 payload (%+v) must not include %q", opts, field)
+			}
+		}
+	}
+}
+
+// validate subconjunto de JSON Schema que usa schema.Generate: type, properties,
+// required, additionalProperties e items.
+func validate(v interface{}, s map[string]interface{}, path string) []string {
+	var errs []string
+
+	if !typeMatches(v, s["type"]) {
+		return []string{fmt.Sprintf("%s: %T does not match type %v", path, v, s["type"])}
+	}
+
+	switch val := v.(type) {
+	case map[string]interface{}:
+		props, _ := s["properties"].(map[string]interface{})
+		if required, ok := s["required"].([]interface{}); ok {
+			for _, r := range required {
+				if _, ok := val[r.(string)]; !ok {
+					errs = append(errs, fmt.Sprintf("%s: missing required %q", path, r))
+				}
+			}
+		}
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if props == nil {
+				if extra, ok := s["additionalProperties"].(map[string]interface{}); ok {
+					errs = append(errs, validate(val[k], extra, path+"."+k)...)
+				}
+				continue
+			}
+			ps, ok := props[k].(map[string]interface{})
+			if !ok {
+				if s["additionalProperties"] == false {
+					errs = append(errs, fmt.Sprintf("%s: unexpected property %q", path, k))
+				}
+				continue
+			}
+			errs = append(errs, validate(val[k], ps, path+"."+k)...)
+		}
+	case []interface{}:
+		if items, ok := s["items"].(map[string]interface{}); ok {
+			for i, item := range val {
+				errs = append(errs, validate(item, items, fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+	}
+	return errs
+}
+
+func typeMatches(v interface{}, typ interface{}) bool {
+	switch t := typ.(type) {
+	case nil:
+		return true
+	case string:
+		return jsonType(v, t)
+	case []interface{}:
+		for _, option := range t {
+			if jsonType(v, option.(string)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func jsonType(v interface{}, t string) bool {
+	switch t {
+	case "null":
+		return v == nil
+	case "object":
+		_, ok := v.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := v.([]interface{})
+		return ok
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	case "number":
+		_, ok := v.(float64)
+		return ok
+	case "integer":
+		f, ok := v.(float64)
+		return ok && f == float64(int64(f))
+	}
+	return false
+}
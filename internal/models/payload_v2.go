@@ -0,0 +1,167 @@
+package models
+
+// WebhookPayloadV2 documento v2 del webhook. Respecto de v1:
+//   - el status y los datos de envío, cliente, dirección y tienda van agrupados
+//   - los ids y números de orden son int64 como en Dropi (v1 usa int en shop_order_number)
+//   - los opcionales son null en vez de "" (sticker)
+//   - no incluye datos sensibles de la tienda (shop_password, webhook)
+//   - changes y transition siempre presentes; history sigue siendo opt-in
+type WebhookPayloadV2 struct {
+	ID         int64                `json:"id"`
+	Type       string               `json:"type"`
+	CreatedAt  string               `json:"created_at"`
+	TotalOrder string               `json:"total_order"`
+	Notes      *string              `json:"notes"`
+	ExternalID *string              `json:"external_id"`
+	SupplierID int64                `json:"supplier_id"`
+	SellerID   *int64               `json:"seller_id"`
+	Status     WebhookStatusV2      `json:"status"`
+	Transition *StatusTransition    `json:"transition"`
+	Changes    []FieldChange        `json:"changes"`
+	Customer   WebhookCustomerV2    `json:"customer"`
+	Address    WebhookAddressV2     `json:"address"`
+	Shipping   WebhookShippingV2    `json:"shipping"`
+	Shop       WebhookShopV2        `json:"shop"`
+	Warehouse  WebhookWarehouseV2   `json:"warehouse"`
+	Items      []WebhookItemV2      `json:"items"`
+	History    []WebhookHistoryItem `json:"history,omitempty"`
+}
+
+type WebhookStatusV2 struct {
+	Raw      string      `json:"raw"`
+	Code     OrderStatus `json:"code"`
+	Label    string      `json:"label"`
+	Terminal bool        `json:"terminal"`
+}
+
+type WebhookCustomerV2 struct {
+	Name    string  `json:"name"`
+	Surname string  `json:"surname"`
+	Phone   string  `json:"phone"`
+	Email   *string `json:"email"`
+	DNIType *string `json:"dni_type"`
+	DNI     *string `json:"dni"`
+}
+
+type WebhookAddressV2 struct {
+	Line    string  `json:"line"`
+	Country string  `json:"country"`
+	State   string  `json:"state"`
+	City    string  `json:"city"`
+	ZipCode *string `json:"zip_code"`
+	Colonia *string `json:"colonia"`
+}
+
+type WebhookShippingV2 struct {
+	Company  string  `json:"company"`
+	Guide    string  `json:"guide"`
+	Sticker  *string `json:"sticker"`
+	RateType string  `json:"rate_type"`
+	Novedad  *string `json:"novedad"`
+}
+
+type WebhookShopV2 struct {
+	ID          int64  `json:"id"`
+	UserID      int64  `json:"user_id"`
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	OrderID     string `json:"order_id"`
+	OrderNumber int64  `json:"order_number"`
+}
+
+type WebhookWarehouseV2 struct {
+	ID   int64   `json:"id"`
+	Name *string `json:"name"`
+}
+
+type WebhookItemV2 struct {
+	ID          int64  `json:"id"`
+	Price       string `json:"price"`
+	ProductID   int64  `json:"product_id"`
+	IDLista     int    `json:"id_lista"`
+	Name        string `json:"name"`
+	NameInOrder string `json:"name_in_order"`
+}
+
+// ToWebhookPayloadV2 convierte un DropiOrder al documento v2.
+func (order DropiOrder) ToWebhookPayloadV2(opts PayloadOptions) WebhookPayloadV2 {
+	status := order.CanonicalStatus()
+
+	changes := order.FieldChanges
+	if changes == nil {
+		changes = []FieldChange{}
+	}
+
+	items := make([]WebhookItemV2, len(order.OrderDetails))
+	for i, detail := range order.OrderDetails {
+		items[i] = WebhookItemV2{
+			ID:          detail.ID,
+			Price:       detail.Price,
+			ProductID:   detail.Product.ID,
+			IDLista:     detail.Product.IDLista,
+			Name:        detail.Product.Name,
+			NameInOrder: detail.Product.NameInOrder,
+		}
+	}
+
+	payload := WebhookPayloadV2{
+		ID:         order.ID,
+		Type:       order.Type,
+		CreatedAt:  order.CreatedAt,
+		TotalOrder: order.TotalOrder,
+		Notes:      order.Notes,
+		ExternalID: order.ExternalID,
+		SupplierID: order.SupplierID,
+		SellerID:   order.SellerID,
+		Status: WebhookStatusV2{
+			Raw:      order.Status,
+			Code:     status,
+			Label:    status.Label(),
+			Terminal: status.IsTerminal(),
+		},
+		Transition: order.Transition,
+		Changes:    changes,
+		Customer: WebhookCustomerV2{
+			Name:    order.Name,
+			Surname: order.Surname,
+			Phone:   order.Phone,
+			Email:   order.Email,
+			DNIType: order.DNIType,
+			DNI:     order.DNI,
+		},
+		Address: WebhookAddressV2{
+			Line:    order.Dir,
+			Country: order.Country,
+			State:   order.State,
+			City:    order.City,
+			ZipCode: order.ZipCode,
+			Colonia: order.Colonia,
+		},
+		Shipping: WebhookShippingV2{
+			Company:  order.ShippingCompany,
+			Guide:    order.ShippingGuide,
+			Sticker:  order.Sticker,
+			RateType: order.RateType,
+			Novedad:  order.Novedad,
+		},
+		Shop: WebhookShopV2{
+			ID:          order.ShopID,
+			UserID:      order.Shop.UserID,
+			Name:        order.Shop.Name,
+			Type:        order.Shop.Type,
+			OrderID:     order.ShopOrderID,
+			OrderNumber: order.ShopOrderNumber,
+		},
+		Warehouse: WebhookWarehouseV2{
+			ID:   warehouseID(&order),
+			Name: order.Warehouse.Name,
+		},
+		Items: items,
+	}
+
+	if opts.History {
+		payload.History = order.ToWebhookHistory(opts.Normalizer, opts.Country)
+	}
+
+	return payload
+}
@@ -2,10 +2,39 @@ package models
 
 // ProcessRequest representa el request para procesar órdenes
 type ProcessRequest struct {
-    APIKey             string `json:"api_key"`
+    // APIKey es la integration key de Dropi. Preferir el header
+    // X-Dropi-Integration-Key: en el body queda en dumps y logs de proxies
+    // (deprecado: solo se acepta con ALLOW_BODY_API_KEY=true)
+    APIKey             Secret `json:"api_key,omitempty"`
     Date               string `json:"date"`
     DropiCountrySuffix string `json:"dropi_country_suffix"`
     WebhookSuffix      string `json:"webhook_suffix"`
+
+    // TenantID es opcional: reemplaza api_key por la key guardada en el registry de tenants;
+    // dropi_country_suffix y webhook_suffix toman los valores del tenant si no vienen
+    TenantID string `json:"tenant_id,omitempty"`
+
+    // WebhookFormat es opcional: "json" (default), "cloudevents-binary" o "cloudevents-structured"
+    WebhookFormat WebhookFormat `json:"webhook_format,omitempty"`
+
+    // Filter es opcional: qué cambios de status disparan el webhook
+    Filter *WebhookFilter `json:"filter,omitempty"`
+
+    // DestinationFilter filtro del destino del tenant (lo completa el
+    // registry); se aplica además de Filter
+    DestinationFilter *WebhookFilter `json:"-"`
+
+    // PayloadOptions es opcional: bloques extra del webhook ("transition", "history")
+    PayloadOptions PayloadOptions `json:"payload_options,omitempty"`
+
+    // PayloadVersion es opcional: "v1" (default) o "v2"; un pin del destino tiene prioridad
+    PayloadVersion PayloadVersion `json:"payload_version,omitempty"`
+
+    // NotifyNewOrders es opcional: envía un evento order.created la primera vez que se ve una orden
+    NotifyNewOrders bool `json:"notify_new_orders,omitempty"`
+
+    // NotifySLABreaches es opcional: envía un evento order.sla_breached cuando una orden incumple su SLA
+    NotifySLABreaches bool `json:"notify_sla_breaches,omitempty"`
 }
 
 // GetDropiCountrySuffix implementa la interfaz del validator
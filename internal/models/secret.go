@@ -0,0 +1,39 @@
+package models
+
+import (
+	"encoding/json"
+	"log/slog"
+)
+
+const redacted = "[REDACTED]"
+
+// Secret valor sensible (la integration key de Dropi). Se decodifica de JSON
+// como un string normal, pero al imprimirlo, loguearlo (zap o slog) o
+// serializarlo solo aparece [REDACTED]; el valor real se obtiene con Reveal.
+type Secret string
+
+// Reveal retorna el valor real; usarlo solo al enviarlo a Dropi.
+func (s Secret) Reveal() string {
+	return string(s)
+}
+
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return redacted
+}
+
+// GoString cubre %#v.
+func (s Secret) GoString() string {
+	return s.String()
+}
+
+// LogValue cubre slog.
+func (s Secret) LogValue() slog.Value {
+	return slog.StringValue(s.String())
+}
+
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
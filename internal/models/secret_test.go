@@ -0,0 +1,82 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const testSecret = "dropi-key-123"
+
+func TestSecretRedaction(t *testing.T) {
+	s := Secret(testSecret)
+	req := ProcessRequest{APIKey: s, Date: "2024-01-10"}
+
+	asJSON := func(v interface{}) string {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+	viaSlog := func(args ...interface{}) string {
+		var buf bytes.Buffer
+		slog.New(slog.NewJSONHandler(&buf, nil)).Info("test", args...)
+		return buf.String()
+	}
+	viaZap := func(fields ...zap.Field) string {
+		var buf bytes.Buffer
+		core := zapcore.NewCore(zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()), zapcore.AddSync(&buf), zap.DebugLevel)
+		zap.New(core).Info("test", fields...)
+		return buf.String()
+	}
+
+	outputs := map[string]string{
+		"String":          s.String(),
+		"%v":              fmt.Sprintf("%v", s),
+		"%s":              fmt.Sprintf("%s", s),
+		"GoString":        s.GoString(),
+		"%#v":             fmt.Sprintf("%#v", s),
+		"%+v request":     fmt.Sprintf("%+v", req),
+		"%#v request":     fmt.Sprintf("%#v", req),
+		"LogValue":        s.LogValue().String(),
+		"slog":            viaSlog("api_key", s),
+		"slog request":    viaSlog("request", req),
+		"MarshalJSON":     asJSON(s),
+		"json request":    asJSON(req),
+		"zap.Any":         viaZap(zap.Any("api_key", s)),
+		"zap.Stringer":    viaZap(zap.Stringer("api_key", s)),
+		"zap.Reflect":     viaZap(zap.Reflect("request", req)),
+		"zap.Any request": viaZap(zap.Any("request", req)),
+	}
+	for name, out := range outputs {
+		if strings.Contains(out, testSecret) {
+			t.Errorf("%s leaks the secret: %s", name, out)
+		}
+		if !strings.Contains(out, redacted) {
+			t.Errorf("%s should show %s: %s", name, redacted, out)
+		}
+	}
+
+	if s.Reveal() != testSecret {
+		t.Fatal("Reveal must return the real value")
+	}
+	if Secret("").String() != "" {
+		t.Fatal("an empty secret should print empty")
+	}
+
+	// Se decodifica como un string normal
+	var decoded ProcessRequest
+	if err := json.Unmarshal([]byte(`{"api_key": "`+testSecret+`"}`), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.APIKey.Reveal() != testSecret {
+		t.Fatalf("api_key decoded as %q", decoded.APIKey.Reveal())
+	}
+}
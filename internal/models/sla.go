@@ -0,0 +1,69 @@
+package models
+
+import "time"
+
+// SLABreach orden que lleva en su status más tiempo del permitido.
+type SLABreach struct {
+	OrderID         int64       `json:"order_id"`
+	Country         string      `json:"country"`
+	ShopID          int64       `json:"shop_id"`
+	Status          OrderStatus `json:"status"`
+	ShippingCompany string      `json:"shipping_company"`
+	ShippingGuide   string      `json:"shipping_guide"`
+	Since           time.Time   `json:"since"`     // entrada al status actual
+	Threshold       Duration    `json:"threshold"` // tiempo máximo configurado
+	InStatus        Duration    `json:"in_status"` // tiempo en el status al detectarlo
+	DetectedAt      time.Time   `json:"detected_at"`
+	LastSeenAt      time.Time   `json:"last_seen_at"`
+	Notified        bool        `json:"notified"`
+}
+
+// Duration time.Duration que se serializa como texto ("72h0m0s").
+type Duration time.Duration
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// SLABreachPayload documento v1 del evento order.sla_breached; se versiona
+// aparte de order.status_changed (ver eventPayloadVersions).
+type SLABreachPayload struct {
+	Event          WebhookEvent      `json:"event"`
+	ID             int64             `json:"id"`
+	Status         WebhookStatusV2   `json:"status"`
+	Since          time.Time         `json:"since"`
+	HoursInStatus  float64           `json:"hours_in_status"`
+	ThresholdHours float64           `json:"threshold_hours"`
+	Shipping       WebhookShippingV2 `json:"shipping"`
+	Customer       WebhookCustomerV2 `json:"customer"`
+	Shop           WebhookShopV2     `json:"shop"`
+}
+
+// ToSLABreachPayload documento order.sla_breached; requiere order.SLABreach.
+func (order DropiOrder) ToSLABreachPayload() SLABreachPayload {
+	v2 := order.ToWebhookPayloadV2(PayloadOptions{})
+
+	payload := SLABreachPayload{
+		Event:    EventSLABreached,
+		ID:       order.ID,
+		Status:   v2.Status,
+		Shipping: v2.Shipping,
+		Customer: v2.Customer,
+		Shop:     v2.Shop,
+	}
+	if b := order.SLABreach; b != nil {
+		payload.Since = b.Since
+		payload.HoursInStatus = time.Duration(b.InStatus).Hours()
+		payload.ThresholdHours = time.Duration(b.Threshold).Hours()
+	}
+	return payload
+}
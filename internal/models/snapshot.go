@@ -0,0 +1,92 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Campos de la orden que pueden vigilarse además del status.
+const (
+	FieldStatus          = "status"
+	FieldShippingGuide   = "shipping_guide"
+	FieldShippingCompany = "shipping_company"
+	FieldNovedad         = "novedad_servientrega"
+	FieldSticker         = "sticker"
+)
+
+// DefaultWatchedFields campos vigilados si no se configura otra cosa.
+var DefaultWatchedFields = []string{
+	FieldShippingGuide,
+	FieldShippingCompany,
+	FieldNovedad,
+	FieldSticker,
+}
+
+// IsWatchableField indica si el campo puede vigilarse.
+func IsWatchableField(field string) bool {
+	switch field {
+	case FieldShippingGuide, FieldShippingCompany, FieldNovedad, FieldSticker:
+		return true
+	}
+	return false
+}
+
+// OrderSnapshot últimos valores conocidos de una orden. Se guarda entre
+// ejecuciones para detectar cambios que no quedan en el history (ej. se asigna
+// la guía sin que cambie el status).
+type OrderSnapshot struct {
+	Status          string    `json:"status"`
+	ShippingGuide   string    `json:"shipping_guide"`
+	ShippingCompany string    `json:"shipping_company"`
+	Novedad         string    `json:"novedad_servientrega"`
+	Sticker         string    `json:"sticker"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// FieldChange diferencia de un campo entre el snapshot guardado y la orden actual.
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// Snapshot valores actuales de la orden.
+func (order *DropiOrder) Snapshot() OrderSnapshot {
+	return OrderSnapshot{
+		Status:          order.Status,
+		ShippingGuide:   strings.TrimSpace(order.ShippingGuide),
+		ShippingCompany: strings.TrimSpace(order.ShippingCompany),
+		Novedad:         strings.TrimSpace(getStickerValue(order.Novedad)),
+		Sticker:         strings.TrimSpace(getStickerValue(order.Sticker)),
+		UpdatedAt:       time.Now().UTC(),
+	}
+}
+
+// Value valor del campo en el snapshot.
+func (s OrderSnapshot) Value(field string) string {
+	switch field {
+	case FieldStatus:
+		return s.Status
+	case FieldShippingGuide:
+		return s.ShippingGuide
+	case FieldShippingCompany:
+		return s.ShippingCompany
+	case FieldNovedad:
+		return s.Novedad
+	case FieldSticker:
+		return s.Sticker
+	}
+	return ""
+}
+
+// Diff cambios en los campos vigilados entre prev y s.
+func (s OrderSnapshot) Diff(prev OrderSnapshot, fields []string) []FieldChange {
+	var changes []FieldChange
+	for _, field := range fields {
+		old, cur := prev.Value(field), s.Value(field)
+		if old != cur {
+			changes = append(changes, FieldChange{Field: field, Old: old, New: cur})
+		}
+	}
+	return changes
+}
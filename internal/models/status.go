@@ -0,0 +1,192 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// OrderStatus status canónico de una orden. Dropi usa distintas grafías y
+// acentos según el país ("EN TRANSITO", "EN TRÁNSITO", "GUIA GENERADA", ...);
+// todas se normalizan a un mismo código para compararlas.
+type OrderStatus string
+
+const (
+	StatusPendienteConfirmacion OrderStatus = "PENDIENTE_CONFIRMACION"
+	StatusPendiente             OrderStatus = "PENDIENTE"
+	StatusGuiaGenerada          OrderStatus = "GUIA_GENERADA"
+	StatusPreparado             OrderStatus = "PREPARADO_PARA_TRANSPORTADORA"
+	StatusEnBodega              OrderStatus = "EN_BODEGA_TRANSPORTADORA"
+	StatusEnTransito            OrderStatus = "EN_TRANSITO"
+	StatusEnReparto             OrderStatus = "EN_REPARTO"
+	StatusNovedad               OrderStatus = "NOVEDAD"
+	StatusNovedadSolucionada    OrderStatus = "NOVEDAD_SOLUCIONADA"
+	StatusEntregado             OrderStatus = "ENTREGADO"
+	StatusDevolucion            OrderStatus = "DEVOLUCION"
+	StatusDevuelto              OrderStatus = "DEVUELTO"
+	StatusCancelado             OrderStatus = "CANCELADO"
+	StatusRechazado             OrderStatus = "RECHAZADO"
+)
+
+// statusInfo metadatos de cada status canónico.
+type statusInfo struct {
+	label    string
+	terminal bool
+}
+
+var knownStatuses = map[OrderStatus]statusInfo{
+	StatusPendienteConfirmacion: {"Pendiente de confirmación", false},
+	StatusPendiente:             {"Pendiente", false},
+	StatusGuiaGenerada:          {"Guía generada", false},
+	StatusPreparado:             {"Preparado para transportadora", false},
+	StatusEnBodega:              {"En bodega de la transportadora", false},
+	StatusEnTransito:            {"En tránsito", false},
+	StatusEnReparto:             {"En reparto", false},
+	StatusNovedad:               {"Novedad", false},
+	StatusNovedadSolucionada:    {"Novedad solucionada", false},
+	StatusEntregado:             {"Entregado", true},
+	StatusDevolucion:            {"En devolución", false},
+	StatusDevuelto:              {"Devuelto", true},
+	StatusCancelado:             {"Cancelado", true},
+	StatusRechazado:             {"Rechazado", true},
+}
+
+// IsKnown indica si el status es uno de los códigos canónicos.
+func (s OrderStatus) IsKnown() bool {
+	_, ok := knownStatuses[s]
+	return ok
+}
+
+// IsTerminal indica si la orden ya no debería cambiar de status.
+func (s OrderStatus) IsTerminal() bool {
+	return knownStatuses[s].terminal
+}
+
+// Label nombre legible del status; para status desconocidos retorna el código.
+func (s OrderStatus) Label() string {
+	if info, ok := knownStatuses[s]; ok {
+		return info.label
+	}
+	return string(s)
+}
+
+// defaultStatusAliases grafías conocidas que no coinciden con el código
+// canónico después de la normalización básica.
+var defaultStatusAliases = map[string]OrderStatus{
+	"PENDIENTE_DE_CONFIRMACION": StatusPendienteConfirmacion,
+	"GUIA_GENERADA":             StatusGuiaGenerada,
+	"PREPARADO":                 StatusPreparado,
+	"EN_BODEGA":                 StatusEnBodega,
+	"TRANSITO":                  StatusEnTransito,
+	"EN_DEVOLUCION":             StatusDevolucion,
+	"DEVOLUCION_EN_TRANSITO":    StatusDevolucion,
+	"ENTREGADA":                 StatusEntregado,
+	"CANCELADA":                 StatusCancelado,
+}
+
+// StatusNormalizer traduce el status crudo de Dropi al código canónico. Los
+// alias por país (clave = dropi_country_suffix) tienen prioridad sobre los
+// globales.
+type StatusNormalizer struct {
+	global    map[string]OrderStatus
+	byCountry map[string]map[string]OrderStatus
+}
+
+// NewStatusNormalizer normalizador con los alias por defecto.
+func NewStatusNormalizer() *StatusNormalizer {
+	global := make(map[string]OrderStatus, len(defaultStatusAliases))
+	for raw, status := range defaultStatusAliases {
+		global[raw] = status
+	}
+	return &StatusNormalizer{
+		global:    global,
+		byCountry: make(map[string]map[string]OrderStatus),
+	}
+}
+
+// LoadStatusNormalizer agrega a los alias por defecto los de un archivo JSON:
+//
+//	{"*": {"EN TRANSITO": "EN_TRANSITO"}, "ec": {"ENTREGADO A CLIENTE": "ENTREGADO"}}
+//
+// La clave "*" aplica a todos los países.
+func LoadStatusNormalizer(path string) (*StatusNormalizer, error) {
+	n := NewStatusNormalizer()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading status aliases: %w", err)
+	}
+
+	var file map[string]map[string]string
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid status aliases JSON: %w", err)
+	}
+
+	for country, aliases := range file {
+		for raw, canonical := range aliases {
+			n.AddAlias(country, raw, OrderStatus(normalizeStatusText(canonical)))
+		}
+	}
+	return n, nil
+}
+
+// AddAlias registra una grafía para un país ("*" o "" = todos los países).
+func (n *StatusNormalizer) AddAlias(country, raw string, status OrderStatus) {
+	key := normalizeStatusText(raw)
+	if country == "" || country == "*" {
+		n.global[key] = status
+		return
+	}
+
+	country = strings.ToLower(country)
+	if n.byCountry[country] == nil {
+		n.byCountry[country] = make(map[string]OrderStatus)
+	}
+	n.byCountry[country][key] = status
+}
+
+// Normalize retorna el código canónico del status crudo. Si no es un status
+// conocido retorna la forma normalizada (mayúsculas, sin acentos, con "_"),
+// que sigue sirviendo para comparar grafías equivalentes.
+func (n *StatusNormalizer) Normalize(raw, country string) OrderStatus {
+	key := normalizeStatusText(raw)
+	if key == "" {
+		return ""
+	}
+
+	if n != nil {
+		if aliases, ok := n.byCountry[strings.ToLower(country)]; ok {
+			if status, ok := aliases[key]; ok {
+				return status
+			}
+		}
+		if status, ok := n.global[key]; ok {
+			return status
+		}
+	}
+
+	return OrderStatus(key)
+}
+
+// NormalizeStatus normaliza con los alias por defecto.
+func NormalizeStatus(raw string) OrderStatus {
+	return defaultNormalizer.Normalize(raw, "")
+}
+
+var defaultNormalizer = NewStatusNormalizer()
+
+var accentReplacer = strings.NewReplacer(
+	"Á", "A", "É", "E", "Í", "I", "Ó", "O", "Ú", "U", "Ü", "U", "Ñ", "N",
+	"á", "A", "é", "E", "í", "I", "ó", "O", "ú", "U", "ü", "U", "ñ", "N",
+)
+
+// normalizeStatusText mayúsculas, sin acentos y con "_" como separador:
+// "En Tránsito" → "EN_TRANSITO", "GUIA GENERADA" → "GUIA_GENERADA".
+func normalizeStatusText(raw string) string {
+	s := strings.ToUpper(accentReplacer.Replace(strings.TrimSpace(raw)))
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return r == ' ' || r == '_' || r == '-' || r == '\t'
+	})
+	return strings.Join(fields, "_")
+}
@@ -0,0 +1,93 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// timestampLayouts formatos de fecha que se han visto en Dropi. Los que no
+// traen zona horaria se interpretan en UTC.
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+// ParseTimestamp interpreta una fecha de Dropi probando los formatos conocidos.
+func ParseTimestamp(raw string) (time.Time, error) {
+	s := strings.TrimSpace(raw)
+	if s == "" {
+		return time.Time{}, fmt.Errorf("empty timestamp")
+	}
+	for _, layout := range timestampLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized timestamp %q", raw)
+}
+
+// OrderWarning dato de la orden que no se pudo interpretar. La orden se procesa
+// igual; el warning queda en el resultado para revisarlo.
+type OrderWarning struct {
+	Field   string `json:"field"`
+	Value   string `json:"value"`
+	Message string `json:"message"`
+}
+
+// CreatedTime fecha de creación de la orden.
+func (order *DropiOrder) CreatedTime() (time.Time, error) {
+	return ParseTimestamp(order.CreatedAt)
+}
+
+// CreatedTime fecha del item del history.
+func (h HistoryItem) CreatedTime() (time.Time, error) {
+	return ParseTimestamp(h.CreatedAt)
+}
+
+// Total total de la orden en la moneda del país.
+func (order *DropiOrder) Total(countrySuffix string) (Money, error) {
+	return ParseMoney(order.TotalOrder, CurrencyForCountry(countrySuffix))
+}
+
+// PriceMoney precio del detalle en la moneda indicada.
+func (d OrderDetail) PriceMoney(currency string) (Money, error) {
+	return ParseMoney(d.Price, currency)
+}
+
+// Warnings revisa que montos y fechas de la orden se puedan interpretar.
+func (order *DropiOrder) Warnings(countrySuffix string) []OrderWarning {
+	var warnings []OrderWarning
+	add := func(field, value string, err error) {
+		if err != nil {
+			warnings = append(warnings, OrderWarning{Field: field, Value: value, Message: err.Error()})
+		}
+	}
+
+	currency := CurrencyForCountry(countrySuffix)
+	if currency == "" {
+		add("currency", countrySuffix, fmt.Errorf("unknown currency for country %q", countrySuffix))
+	}
+
+	_, err := order.Total(countrySuffix)
+	add("total_order", order.TotalOrder, err)
+
+	_, err = order.CreatedTime()
+	add("created_at", order.CreatedAt, err)
+
+	for i, d := range order.OrderDetails {
+		_, err := d.PriceMoney(currency)
+		add(fmt.Sprintf("orderdetails[%d].price", i), d.Price, err)
+	}
+	for i, h := range order.History {
+		_, err := h.CreatedTime()
+		add(fmt.Sprintf("history[%d].created_at", i), h.CreatedAt, err)
+	}
+
+	return warnings
+}
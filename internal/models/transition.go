@@ -0,0 +1,91 @@
+package models
+
+// PayloadOptions bloques opcionales del webhook. Son opt-in por request y solo
+// aplican al payload v2; el documento v1 no cambia.
+type PayloadOptions struct {
+	// Transition agrega el bloque "transition" (status anterior, fecha, autor)
+	Transition bool `json:"transition,omitempty"`
+	// History agrega el history completo de la orden
+	History bool `json:"history,omitempty"`
+
+	// Normalizer y Country los fija el servicio (no vienen del request): los
+	// códigos del history usan los alias del país, igual que el comparador.
+	// Sin Normalizer se usan los alias por defecto.
+	Normalizer *StatusNormalizer `json:"-"`
+	Country    string            `json:"-"`
+}
+
+// StatusTransition contexto del último cambio de status de la orden.
+type StatusTransition struct {
+	PreviousStatus string           `json:"previous_status"`
+	PreviousCode   OrderStatus      `json:"previous_status_code"`
+	NewStatus      string           `json:"new_status"`
+	NewCode        OrderStatus      `json:"new_status_code"`
+	ChangedAt      string           `json:"changed_at"`
+	HistoryID      int64            `json:"history_id"`
+	Actor          *TransitionActor `json:"actor,omitempty"`
+}
+
+// TransitionActor quién hizo el cambio: un usuario de Dropi o del chat center.
+type TransitionActor struct {
+	Type  string `json:"type"` // user | chatcenter
+	Name  string `json:"name"`
+	Role  string `json:"role,omitempty"`
+	Email string `json:"email,omitempty"`
+}
+
+// WebhookHistoryItem item del history en el webhook.
+type WebhookHistoryItem struct {
+	ID            int64            `json:"id"`
+	Status        string           `json:"status"`
+	StatusCode    OrderStatus      `json:"status_code"`
+	CreatedAt     string           `json:"created_at"`
+	ShippingGuide *string          `json:"shipping_guide,omitempty"`
+	Actor         *TransitionActor `json:"actor,omitempty"`
+}
+
+// NewStatusTransition arma la transición entre dos items del history; los
+// códigos canónicos los calcula quien conoce los alias del país.
+func NewStatusTransition(prev, last HistoryItem, prevCode, newCode OrderStatus) *StatusTransition {
+	return &StatusTransition{
+		PreviousStatus: prev.Status,
+		PreviousCode:   prevCode,
+		NewStatus:      last.Status,
+		NewCode:        newCode,
+		ChangedAt:      last.CreatedAt,
+		HistoryID:      last.ID,
+		Actor:          last.Actor(),
+	}
+}
+
+// Actor autor del item; nil si Dropi no lo informa.
+func (h HistoryItem) Actor() *TransitionActor {
+	if h.User != nil {
+		name := h.User.Name
+		if h.User.Surname != "" {
+			name += " " + h.User.Surname
+		}
+		return &TransitionActor{Type: "user", Name: name, Role: h.User.RoleUser.Name}
+	}
+	if h.ChatUser != nil {
+		return &TransitionActor{Type: "chatcenter", Name: h.ChatUser.Name, Email: h.ChatUser.Email}
+	}
+	return nil
+}
+
+// ToWebhookHistory convierte el history de la orden; los códigos se calculan
+// con los alias del país, como el status de la orden.
+func (order DropiOrder) ToWebhookHistory(n *StatusNormalizer, country string) []WebhookHistoryItem {
+	items := make([]WebhookHistoryItem, len(order.History))
+	for i, h := range order.History {
+		items[i] = WebhookHistoryItem{
+			ID:            h.ID,
+			Status:        h.Status,
+			StatusCode:    n.Normalize(h.Status, country),
+			CreatedAt:     h.CreatedAt,
+			ShippingGuide: h.Guide,
+			Actor:         h.Actor(),
+		}
+	}
+	return items
+}
@@ -0,0 +1,36 @@
+package models
+
+import "testing"
+
+func TestWebhookHistoryUsesCountryAliases(t *testing.T) {
+	n := NewStatusNormalizer()
+	n.AddAlias("ec", "ENTREGADO A CLIENTE", StatusEntregado)
+
+	order := DropiOrder{History: []HistoryItem{
+		{ID: 1, Status: "PENDIENTE"},
+		{ID: 2, Status: "Entregado a cliente"},
+	}}
+
+	tests := []struct {
+		name    string
+		n       *StatusNormalizer
+		country string
+		want    OrderStatus
+	}{
+		{"country alias", n, "ec", StatusEntregado},
+		{"other country", n, "co", "ENTREGADO_A_CLIENTE"},
+		{"no normalizer", nil, "ec", "ENTREGADO_A_CLIENTE"},
+	}
+	for _, tt := range tests {
+		items := order.ToWebhookHistory(tt.n, tt.country)
+		if items[0].StatusCode != StatusPendiente || items[1].StatusCode != tt.want {
+			t.Errorf("%s: status codes = %s, %s; want %s, %s", tt.name, items[0].StatusCode, items[1].StatusCode, StatusPendiente, tt.want)
+		}
+	}
+
+	// Los payloads toman el normalizador de las opciones que fija el servicio
+	opts := PayloadOptions{History: true, Normalizer: n, Country: "ec"}
+	if got := order.ToWebhookPayloadV2(opts).History[1].StatusCode; got != StatusEntregado {
+		t.Fatalf("v2 history status_code = %s, want %s", got, StatusEntregado)
+	}
+}
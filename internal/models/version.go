@@ -0,0 +1,54 @@
+package models
+
+import "reflect"
+
+// PayloadVersion contrato del documento que recibe el webhook. Un cambio de
+// forma del payload requiere una versión nueva; las existentes no se tocan.
+type PayloadVersion string
+
+const (
+	// PayloadV1 documento histórico (WebhookPayload)
+	PayloadV1 PayloadVersion = "v1"
+	// PayloadV2 documento agrupado por secciones, sin datos sensibles de la tienda (WebhookPayloadV2)
+	PayloadV2 PayloadVersion = "v2"
+
+	// DefaultPayloadVersion versión cuando ni el destino ni el request indican otra
+	DefaultPayloadVersion = PayloadV1
+)
+
+// PayloadVersions versiones publicadas, de la más antigua a la más nueva.
+var PayloadVersions = []PayloadVersion{PayloadV1, PayloadV2}
+
+// IsValid indica si la versión es conocida; vacío equivale a la default.
+func (v PayloadVersion) IsValid() bool {
+	return v == "" || v.PayloadType() != nil
+}
+
+// OrDefault retorna la versión o la default si está vacía.
+func (v PayloadVersion) OrDefault() PayloadVersion {
+	if v == "" {
+		return DefaultPayloadVersion
+	}
+	return v
+}
+
+// PayloadType tipo Go que define el contrato de la versión (para generar el
+// JSON Schema); nil si la versión no existe.
+func (v PayloadVersion) PayloadType() reflect.Type {
+	switch v {
+	case PayloadV1:
+		return reflect.TypeOf(WebhookPayload{})
+	case PayloadV2:
+		return reflect.TypeOf(WebhookPayloadV2{})
+	}
+	return nil
+}
+
+// ToVersionedPayload documento de la orden en la versión pedida. Las opciones
+// solo aplican a v2: v1 no recibe campos nuevos.
+func (order DropiOrder) ToVersionedPayload(v PayloadVersion, opts PayloadOptions) interface{} {
+	if v.OrDefault() == PayloadV2 {
+		return order.ToWebhookPayloadV2(opts)
+	}
+	return order.ToWebhookPayload()
+}
@@ -0,0 +1,327 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Jitter define cómo se aleatoriza el backoff entre intentos.
+type Jitter string
+
+const (
+	// JitterNone backoff exponencial puro
+	JitterNone Jitter = "none"
+	// JitterFull espera un valor aleatorio entre 0 y el backoff exponencial
+	JitterFull Jitter = "full"
+	// JitterDecorrelated espera un valor aleatorio entre BaseDelay y 3x la espera anterior
+	JitterDecorrelated Jitter = "decorrelated"
+)
+
+// DefaultRetryableStatus códigos HTTP que vale la pena reintentar.
+// Cualquier otro código (400, 404, 422, ...) se considera permanente.
+var DefaultRetryableStatus = map[int]bool{
+	http.StatusRequestTimeout:      true,
+	http.StatusTooEarly:            true,
+	http.StatusTooManyRequests:     true,
+	http.StatusInternalServerError: true,
+	http.StatusBadGateway:          true,
+	http.StatusServiceUnavailable:  true,
+	http.StatusGatewayTimeout:      true,
+}
+
+// Policy configura los reintentos de una operación.
+type Policy struct {
+	MaxAttempts int           // total de intentos, incluyendo el primero
+	BaseDelay   time.Duration // espera base del backoff
+	MaxDelay    time.Duration // tope de espera entre intentos (0 = tope de 1h)
+	MaxElapsed  time.Duration // tiempo total máximo reintentando (0 = sin límite)
+	Jitter      Jitter
+
+	// RetryableStatus códigos HTTP reintentables para errores *StatusError
+	RetryableStatus map[int]bool
+
+	// IsPermanent permite marcar errores adicionales como no reintentables
+	IsPermanent func(error) bool
+}
+
+// DefaultPolicy 3 intentos, backoff desde 1s con full jitter y tope de 30s.
+func DefaultPolicy() Policy {
+	return Policy{
+		MaxAttempts:     3,
+		BaseDelay:       time.Second,
+		MaxDelay:        30 * time.Second,
+		MaxElapsed:      2 * time.Minute,
+		Jitter:          JitterFull,
+		RetryableStatus: DefaultRetryableStatus,
+	}
+}
+
+// PolicyFromEnv parte de DefaultPolicy y aplica las variables con el prefijo
+// dado, por ejemplo con prefix "WEBHOOK_RETRY":
+//
+//	WEBHOOK_RETRY_MAX_ATTEMPTS=5
+//	WEBHOOK_RETRY_BASE_DELAY=500ms
+//	WEBHOOK_RETRY_MAX_DELAY=30s
+//	WEBHOOK_RETRY_MAX_ELAPSED=2m
+//	WEBHOOK_RETRY_JITTER=full|decorrelated|none
+//	WEBHOOK_RETRY_STATUSES=408,429,500,502,503,504
+func PolicyFromEnv(prefix string) (Policy, error) {
+	p := DefaultPolicy()
+
+	if v := os.Getenv(prefix + "_MAX_ATTEMPTS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return p, fmt.Errorf("%s_MAX_ATTEMPTS must be a positive integer", prefix)
+		}
+		p.MaxAttempts = n
+	}
+
+	durations := []struct {
+		name string
+		dst  *time.Duration
+	}{
+		{"_BASE_DELAY", &p.BaseDelay},
+		{"_MAX_DELAY", &p.MaxDelay},
+		{"_MAX_ELAPSED", &p.MaxElapsed},
+	}
+	for _, d := range durations {
+		v := os.Getenv(prefix + d.name)
+		if v == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(v)
+		if err != nil || parsed < 0 {
+			return p, fmt.Errorf("%s%s must be a valid duration (e.g. 500ms, 30s)", prefix, d.name)
+		}
+		*d.dst = parsed
+	}
+
+	if v := os.Getenv(prefix + "_JITTER"); v != "" {
+		switch j := Jitter(strings.ToLower(v)); j {
+		case JitterNone, JitterFull, JitterDecorrelated:
+			p.Jitter = j
+		default:
+			return p, fmt.Errorf("%s_JITTER must be one of: none, full, decorrelated", prefix)
+		}
+	}
+
+	if v := os.Getenv(prefix + "_STATUSES"); v != "" {
+		statuses := make(map[int]bool)
+		for _, part := range strings.Split(v, ",") {
+			code, err := strconv.Atoi(strings.TrimSpace(part))
+			if err != nil {
+				return p, fmt.Errorf("%s_STATUSES must be a comma separated list of HTTP codes", prefix)
+			}
+			statuses[code] = true
+		}
+		p.RetryableStatus = statuses
+	}
+
+	return p, nil
+}
+
+// StatusError representa una respuesta HTTP no exitosa. RetryAfter viene del
+// header Retry-After del receptor (0 si no lo envió).
+type StatusError struct {
+	StatusCode int
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("request failed with status %d", e.StatusCode)
+}
+
+// NewStatusError construye el error a partir de la respuesta HTTP.
+func NewStatusError(resp *http.Response) *StatusError {
+	return &StatusError{
+		StatusCode: resp.StatusCode,
+		RetryAfter: ParseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+	}
+}
+
+// ParseRetryAfter interpreta Retry-After en segundos o como fecha HTTP.
+func ParseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+
+	if secs, err := strconv.Atoi(value); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+
+	if t, err := http.ParseTime(value); err == nil {
+		if d := t.Sub(now); d > 0 {
+			return d
+		}
+	}
+
+	return 0
+}
+
+// RetryAfter retorna el Retry-After de un *StatusError envuelto en err (0 si no hay).
+func RetryAfter(err error) time.Duration {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.RetryAfter
+	}
+	return 0
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marca un error como no reintentable.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// isPermanent decide si el error corta los reintentos.
+func (p Policy) isPermanent(err error) bool {
+	var perm *permanentError
+	if errors.As(err, &perm) {
+		return true
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		retryable := p.RetryableStatus
+		if retryable == nil {
+			retryable = DefaultRetryableStatus
+		}
+		if !retryable[statusErr.StatusCode] {
+			return true
+		}
+	}
+
+	return p.IsPermanent != nil && p.IsPermanent(err)
+}
+
+// Retryable indica si la política reintentaría el error.
+func (p Policy) Retryable(err error) bool {
+	return err != nil && !p.isPermanent(err)
+}
+
+// maxBackoff tope de la espera cuando la política no define MaxDelay; evita
+// que el backoff exponencial desborde time.Duration con muchos intentos.
+const maxBackoff = time.Hour
+
+// Backoff calcula la espera antes del siguiente intento. attempt es el intento
+// que acaba de fallar (1-based) y prev la espera usada antes de ese intento.
+func (p Policy) Backoff(attempt int, prev time.Duration) time.Duration {
+	limit := p.MaxDelay
+	if limit <= 0 {
+		limit = maxBackoff
+	}
+	base := p.BaseDelay
+	if base <= 0 {
+		base = time.Second
+	}
+	if base > limit {
+		base = limit
+	}
+
+	var delay time.Duration
+	switch p.Jitter {
+	case JitterDecorrelated:
+		if prev < base {
+			prev = base
+		}
+		if prev > limit {
+			prev = limit
+		}
+		upper := prev * 3
+		delay = base + time.Duration(rand.Int63n(int64(upper-base)+1))
+
+	default:
+		delay = base
+		for i := 1; i < attempt && delay < limit; i++ {
+			delay *= 2
+		}
+		if delay > limit {
+			delay = limit
+		}
+		if p.Jitter == JitterFull {
+			delay = time.Duration(rand.Int63n(int64(delay) + 1))
+		}
+	}
+
+	if delay > limit {
+		delay = limit
+	}
+	return delay
+}
+
+// Do ejecuta fn según la política. fn recibe el número de intento (1-based).
+// Se detiene ante un error permanente, al agotar los intentos o al superar
+// MaxElapsed. Un Retry-After del receptor mayor al backoff calculado se respeta.
+func (p Policy) Do(ctx context.Context, fn func(attempt int) error) error {
+	attempts := p.MaxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	start := time.Now()
+	var delay time.Duration
+	var err error
+
+	for attempt := 1; attempt <= attempts; attempt++ {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		err = fn(attempt)
+		if err == nil {
+			return nil
+		}
+
+		// Se retorna el error tal cual: la marca de permanente debe seguir
+		// visible para Retryable/errors.As en quien reprograma la entrega
+		if p.isPermanent(err) {
+			return err
+		}
+
+		// No hacer sleep en el último intento
+		if attempt == attempts {
+			break
+		}
+
+		delay = p.Backoff(attempt, delay)
+
+		var statusErr *StatusError
+		if errors.As(err, &statusErr) && statusErr.RetryAfter > delay {
+			delay = statusErr.RetryAfter
+		}
+
+		if p.MaxElapsed > 0 && time.Since(start)+delay > p.MaxElapsed {
+			return err
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return err
+}
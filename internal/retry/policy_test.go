@@ -0,0 +1,190 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func fastPolicy(attempts int) Policy {
+	return Policy{
+		MaxAttempts:     attempts,
+		BaseDelay:       time.Millisecond,
+		MaxDelay:        2 * time.Millisecond,
+		Jitter:          JitterNone,
+		RetryableStatus: DefaultRetryableStatus,
+	}
+}
+
+func TestDoRetriesRetryableErrors(t *testing.T) {
+	calls := 0
+	err := fastPolicy(3).Do(context.Background(), func(attempt int) error {
+		calls++
+		if attempt < 3 {
+			return &StatusError{StatusCode: http.StatusServiceUnavailable}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("expected success, got %v", err)
+	}
+	if calls != 3 {
+		t.Fatalf("expected 3 attempts, got %d", calls)
+	}
+}
+
+func TestDoStopsOnPermanentStatus(t *testing.T) {
+	calls := 0
+	err := fastPolicy(5).Do(context.Background(), func(int) error {
+		calls++
+		return &StatusError{StatusCode: http.StatusUnprocessableEntity}
+	})
+	if calls != 1 {
+		t.Fatalf("expected 1 attempt, got %d", calls)
+	}
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 status error, got %v", err)
+	}
+}
+
+func TestDoKeepsPermanentMarker(t *testing.T) {
+	p := fastPolicy(5)
+	cause := errors.New("bad request construction")
+
+	calls := 0
+	err := p.Do(context.Background(), func(int) error {
+		calls++
+		return Permanent(cause)
+	})
+	if calls != 1 {
+		t.Fatalf("expected 1 attempt, got %d", calls)
+	}
+	var perm *permanentError
+	if !errors.As(err, &perm) || p.Retryable(err) {
+		t.Fatal("error returned by Do must stay permanent")
+	}
+	if !errors.Is(err, cause) {
+		t.Fatalf("expected wrapped cause, got %v", err)
+	}
+}
+
+func TestDoReturnsLastErrorWhenExhausted(t *testing.T) {
+	calls := 0
+	err := fastPolicy(2).Do(context.Background(), func(int) error {
+		calls++
+		return &StatusError{StatusCode: http.StatusBadGateway}
+	})
+	if calls != 2 {
+		t.Fatalf("expected 2 attempts, got %d", calls)
+	}
+	if !fastPolicy(2).Retryable(err) {
+		t.Fatal("exhausted retryable error should remain retryable")
+	}
+}
+
+func TestDoHonoursContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := fastPolicy(3).Do(ctx, func(int) error {
+		t.Fatal("fn must not run with a cancelled context")
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	p := DefaultPolicy()
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"network", errors.New("connection reset"), true},
+		{"429", &StatusError{StatusCode: http.StatusTooManyRequests}, true},
+		{"503 wrapped", wrap(&StatusError{StatusCode: http.StatusServiceUnavailable}), true},
+		{"404", &StatusError{StatusCode: http.StatusNotFound}, false},
+		{"permanent", Permanent(errors.New("x")), false},
+		{"permanent wrapped", wrap(Permanent(errors.New("x"))), false},
+	}
+	for _, tt := range tests {
+		if got := p.Retryable(tt.err); got != tt.want {
+			t.Errorf("%s: Retryable = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func wrap(err error) error {
+	return &wrapped{err}
+}
+
+type wrapped struct{ err error }
+
+func (w *wrapped) Error() string { return "wrapped: " + w.err.Error() }
+func (w *wrapped) Unwrap() error { return w.err }
+
+func TestBackoffRespectsMaxDelay(t *testing.T) {
+	p := Policy{BaseDelay: time.Second, MaxDelay: 10 * time.Second, Jitter: JitterNone}
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second}
+	for i, w := range want {
+		if got := p.Backoff(i+1, 0); got != w {
+			t.Errorf("attempt %d: Backoff = %v, want %v", i+1, got, w)
+		}
+	}
+
+	p.Jitter = JitterFull
+	for attempt := 1; attempt <= 10; attempt++ {
+		if got := p.Backoff(attempt, 0); got < 0 || got > p.MaxDelay {
+			t.Errorf("full jitter attempt %d out of range: %v", attempt, got)
+		}
+	}
+}
+
+func TestBackoffWithoutMaxDelayDoesNotOverflow(t *testing.T) {
+	// Sin MaxDelay el backoff exponencial desbordaba y rand.Int63n entraba en pánico
+	for _, jitter := range []Jitter{JitterNone, JitterFull, JitterDecorrelated} {
+		p := Policy{BaseDelay: time.Second, Jitter: jitter}
+		prev := time.Duration(0)
+		for _, attempt := range []int{1, 10, 40, 63, 64, 100, 1000} {
+			got := p.Backoff(attempt, prev)
+			if got < 0 || got > maxBackoff {
+				t.Errorf("%s attempt %d: Backoff = %v, want within [0, %v]", jitter, attempt, got, maxBackoff)
+			}
+			prev = got
+		}
+	}
+
+	p := Policy{BaseDelay: time.Second, Jitter: JitterNone}
+	if got := p.Backoff(100, 0); got != maxBackoff {
+		t.Fatalf("Backoff = %v, want the %v ceiling", got, maxBackoff)
+	}
+	if got := p.Backoff(3, 0); got != 4*time.Second {
+		t.Fatalf("Backoff below the ceiling = %v, want 4s", got)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"120", 2 * time.Minute},
+		{"-5", 0},
+		{"Mon, 01 Jan 2024 12:00:30 GMT", 30 * time.Second},
+		{"Mon, 01 Jan 2024 11:00:00 GMT", 0},
+		{"garbage", 0},
+	}
+	for _, tt := range tests {
+		if got := ParseRetryAfter(tt.value, now); got != tt.want {
+			t.Errorf("ParseRetryAfter(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
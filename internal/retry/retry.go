@@ -6,6 +6,9 @@ import (
     "math/rand"
 )
 
+// WithRetry reintenta fn durmiendo entre intentos.
+//
+// Deprecated: usar Policy.Do, que distingue errores permanentes y respeta Retry-After.
 func WithRetry(
     ctx context.Context,
     attempts int,
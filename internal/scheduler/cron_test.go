@@ -0,0 +1,92 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseScheduleNext(t *testing.T) {
+	// miércoles 10 de enero de 2024, 10:07:30
+	base := time.Date(2024, 1, 10, 10, 7, 30, 0, time.UTC)
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2024, 1, 10, 10, 8, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2024, 1, 10, 10, 15, 0, 0, time.UTC)},
+		{"0 9-18 * * *", time.Date(2024, 1, 10, 11, 0, 0, 0, time.UTC)},
+		{"30 8 * * *", time.Date(2024, 1, 11, 8, 30, 0, 0, time.UTC)},
+		{"0,30 * * * *", time.Date(2024, 1, 10, 10, 30, 0, 0, time.UTC)},
+		{"0 0 * * 1-5", time.Date(2024, 1, 11, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2024, 1, 14, 0, 0, 0, 0, time.UTC)}, // 7 = domingo
+		{"0 0 1 * *", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// Día del mes y de la semana restringidos: basta con uno (día 15 o lunes)
+		{"0 0 15 * 1", time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)},
+		{"0 12 10 * 5", time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2024, 1, 10, 11, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2024, 1, 11, 0, 0, 0, 0, time.UTC)},
+		{"@weekly", time.Date(2024, 1, 14, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"@yearly", time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"@every 10m", time.Date(2024, 1, 10, 10, 17, 30, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		s, err := ParseSchedule(tt.expr)
+		if err != nil {
+			t.Errorf("ParseSchedule(%q): %v", tt.expr, err)
+			continue
+		}
+		if got := s.Next(base); !got.Equal(tt.want) {
+			t.Errorf("%q: Next = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestParseScheduleErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"a * * * *",
+		"@every 30s",
+		"@every soon",
+	} {
+		if _, err := ParseSchedule(expr); err == nil {
+			t.Errorf("ParseSchedule(%q) should fail", expr)
+		}
+	}
+}
+
+func TestScheduleNeverFires(t *testing.T) {
+	s, err := ParseSchedule("0 0 31 2 *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Next(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)); !got.IsZero() {
+		t.Fatalf("31 February should never fire, got %v", got)
+	}
+}
+
+func TestScheduleInLocation(t *testing.T) {
+	bogota, err := time.LoadLocation("America/Bogota")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := ParseSchedule("0 8 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 12:00 UTC son las 7:00 en Bogotá (UTC-5)
+	got := s.Next(time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC).In(bogota))
+	if want := time.Date(2024, 1, 10, 13, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Fatalf("Next = %v, want %v", got, want)
+	}
+}
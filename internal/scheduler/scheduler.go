@@ -0,0 +1,597 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"os"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+	_ "time/tzdata" // time_zone funciona aunque la imagen no traiga zoneinfo
+
+	"github.com/juancollazo-ch/dropi-order-status-service/internal/models"
+	"github.com/juancollazo-ch/dropi-order-status-service/internal/service"
+	"github.com/juancollazo-ch/dropi-order-status-service/internal/tenant"
+	"github.com/juancollazo-ch/dropi-order-status-service/internal/validator"
+)
+
+const (
+	defaultRunTimeout  = 2 * time.Minute
+	defaultHistorySize = 20
+)
+
+// Runner procesa el request de un tenant (OrderService.HandleOrderRequest).
+type Runner func(ctx context.Context, req models.ProcessRequest) (*service.ProcessResult, error)
+
+// Job consulta periódica de un tenant.
+type Job struct {
+	Tenant   string
+	Spec     string
+	Schedule Schedule
+	Jitter   time.Duration
+	Timeout  time.Duration
+	Location *time.Location
+
+	// Request plantilla del request; Date se completa en cada ejecución con
+	// la fecha actual en Location
+	Request models.ProcessRequest
+
+	// registry job generado desde el registry de tenants (ReplaceTenantJobs)
+	registry bool
+}
+
+// Run resultado de una ejecución.
+type Run struct {
+	StartedAt       time.Time `json:"started_at"`
+	FinishedAt      time.Time `json:"finished_at,omitempty"`
+	Duration        string    `json:"duration,omitempty"`
+	Status          string    `json:"status"` // ok | error | skipped
+	Error           string    `json:"error,omitempty"`
+	Date            string    `json:"date,omitempty"`
+	OrdersProcessed int       `json:"orders_processed"`
+	ChangesDetected int       `json:"changes_detected"`
+	WebhooksQueued  int       `json:"webhooks_queued"`
+}
+
+// JobStatus estado de un job para el endpoint de administración.
+type JobStatus struct {
+	Tenant   string    `json:"tenant"`
+	Schedule string    `json:"schedule"`
+	Running  bool      `json:"running"`
+	NextRun  time.Time `json:"next_run,omitempty"`
+	Runs     []Run     `json:"runs"` // la más reciente primero
+}
+
+type jobState struct {
+	job     Job
+	running bool
+	nextRun time.Time
+	runs    []Run
+	stop    context.CancelFunc // detiene el loop del job (no la ejecución en curso)
+}
+
+// Scheduler ejecuta los jobs según su expresión cron. Si la ejecución anterior
+// de un job sigue activa, la nueva se omite (queda registrada como skipped).
+//
+// La prevención de solapamiento es local a la instancia: no hay lease entre
+// instancias, así que el scheduler requiere una sola instancia siempre activa
+// (en Cloud Run, min-instances=1 y max-instances=1, o CPU siempre asignada);
+// con varias instancias cada una ejecuta todos los jobs.
+type Scheduler struct {
+	runner      Runner
+	historySize int
+
+	mu   sync.Mutex
+	ctx  context.Context // el de Start; nil antes de iniciar
+	jobs map[string]*jobState
+	wg   sync.WaitGroup
+}
+
+func NewScheduler(runner Runner, jobs []Job, historySize int) *Scheduler {
+	if historySize < 1 {
+		historySize = defaultHistorySize
+	}
+	s := &Scheduler{
+		runner:      runner,
+		historySize: historySize,
+		jobs:        make(map[string]*jobState, len(jobs)),
+	}
+	for _, job := range jobs {
+		job = withDefaults(job)
+		s.jobs[job.Tenant] = &jobState{job: job}
+	}
+	return s
+}
+
+func withDefaults(job Job) Job {
+	if job.Timeout <= 0 {
+		job.Timeout = defaultRunTimeout
+	}
+	if job.Location == nil {
+		job.Location = time.UTC
+	}
+	return job
+}
+
+// Len cantidad de jobs configurados.
+func (s *Scheduler) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.jobs)
+}
+
+// Start lanza un loop por job; terminan al cancelar ctx.
+func (s *Scheduler) Start(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ctx = ctx
+	for _, st := range s.jobs {
+		s.startLocked(st)
+	}
+	slog.Info("scheduler started", "jobs", len(s.jobs))
+}
+
+// startLocked lanza el loop del job con su configuración actual; requiere s.mu.
+func (s *Scheduler) startLocked(st *jobState) {
+	ctx, stop := context.WithCancel(s.ctx)
+	st.stop = stop
+	s.wg.Add(1)
+	go s.loop(ctx, st, st.job)
+}
+
+// ReplaceTenantJobs reemplaza los jobs generados desde el registry de
+// tenants (TenantJobs), por ejemplo después de editar un tenant. Los jobs sin
+// cambios conservan su loop; los modificados se reinician conservando su
+// historial, y una ejecución en curso de un job quitado termina normalmente.
+func (s *Scheduler) ReplaceTenantJobs(jobs []Job) {
+	next := make(map[string]Job, len(jobs))
+	for _, job := range jobs {
+		job = withDefaults(job)
+		job.registry = true
+		next[job.Tenant] = job
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for name, st := range s.jobs {
+		if !st.job.registry {
+			continue
+		}
+		job, ok := next[name]
+		if ok && sameJob(st.job, job) {
+			delete(next, name)
+			continue
+		}
+		if st.stop != nil {
+			st.stop()
+		}
+		if !ok {
+			delete(s.jobs, name)
+			continue
+		}
+		// Mismo nombre con otra configuración: se reinicia el loop
+		st.job = job
+		delete(next, name)
+		if s.ctx != nil {
+			s.startLocked(st)
+		}
+	}
+
+	for name, job := range next {
+		if _, taken := s.jobs[name]; taken {
+			// Un job del archivo con el mismo nombre tiene prioridad
+			continue
+		}
+		st := &jobState{job: job}
+		s.jobs[name] = st
+		if s.ctx != nil {
+			s.startLocked(st)
+		}
+	}
+	slog.Info("scheduler: tenant jobs updated", "jobs", len(s.jobs))
+}
+
+func sameJob(a, b Job) bool {
+	return a.Spec == b.Spec &&
+		a.Jitter == b.Jitter &&
+		a.Timeout == b.Timeout &&
+		a.Location.String() == b.Location.String() &&
+		reflect.DeepEqual(a.Request, b.Request)
+}
+
+// Wait espera a que terminen los loops y las ejecuciones en curso.
+func (s *Scheduler) Wait() {
+	s.wg.Wait()
+}
+
+// loop usa su copia de job: ReplaceTenantJobs puede cambiar st.job mientras
+// este loop termina.
+func (s *Scheduler) loop(ctx context.Context, st *jobState, job Job) {
+	defer s.wg.Done()
+
+	for {
+		now := time.Now().In(job.Location)
+		next := job.Schedule.Next(now)
+		if next.IsZero() {
+			slog.Error("scheduler: schedule never fires", "tenant", job.Tenant, "schedule", job.Spec)
+			return
+		}
+		// El jitter reparte en el tiempo los tenants con la misma expresión
+		if job.Jitter > 0 {
+			next = next.Add(time.Duration(rand.Int63n(int64(job.Jitter))))
+		}
+
+		s.mu.Lock()
+		st.nextRun = next
+		s.mu.Unlock()
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		s.trigger(st, job)
+	}
+}
+
+// trigger lanza la ejecución sin bloquear el loop, salvo que la anterior siga
+// activa. La ejecución depende del contexto de Start, no del loop: reemplazar
+// el job no la corta.
+func (s *Scheduler) trigger(st *jobState, job Job) {
+	s.mu.Lock()
+	if st.running {
+		s.record(st, Run{StartedAt: time.Now().UTC(), Status: "skipped", Error: "previous run still active"})
+		s.mu.Unlock()
+		slog.Warn("scheduler: run skipped, previous run still active", "tenant", job.Tenant)
+		return
+	}
+	st.running = true
+	ctx := s.ctx
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		run := s.execute(ctx, job)
+
+		s.mu.Lock()
+		st.running = false
+		s.record(st, run)
+		s.mu.Unlock()
+	}()
+}
+
+func (s *Scheduler) execute(ctx context.Context, job Job) Run {
+	started := time.Now()
+	req := job.Request
+	req.Date = started.In(job.Location).Format("2006-01-02")
+
+	run := Run{StartedAt: started.UTC(), Date: req.Date}
+	logger := slog.With("tenant", job.Tenant, "date", req.Date)
+	logger.Info("scheduler: run started")
+
+	runCtx, cancel := context.WithTimeout(ctx, job.Timeout)
+	defer cancel()
+
+	result, err := s.runner(runCtx, req)
+
+	run.FinishedAt = time.Now().UTC()
+	run.Duration = run.FinishedAt.Sub(started).String()
+	if err != nil {
+		run.Status = "error"
+		run.Error = err.Error()
+		logger.Error("scheduler: run failed", "error", err)
+		return run
+	}
+
+	run.Status = "ok"
+	if result != nil {
+		run.OrdersProcessed = result.OrdersProcessed
+		run.ChangesDetected = result.ChangesDetected
+		run.WebhooksQueued = result.WebhooksQueued
+		if result.PartialTimeout {
+			run.Status = "error"
+			run.Error = "partial timeout"
+		}
+	}
+	logger.Info("scheduler: run finished",
+		"status", run.Status,
+		"orders_processed", run.OrdersProcessed,
+		"webhooks_queued", run.WebhooksQueued,
+	)
+	return run
+}
+
+// record agrega la ejecución al historial del tenant; requiere s.mu.
+func (s *Scheduler) record(st *jobState, run Run) {
+	st.runs = append([]Run{run}, st.runs...)
+	if len(st.runs) > s.historySize {
+		st.runs = st.runs[:s.historySize]
+	}
+}
+
+// Status estado de todos los jobs ordenados por tenant.
+func (s *Scheduler) Status() []JobStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make([]JobStatus, 0, len(s.jobs))
+	for _, st := range s.jobs {
+		out = append(out, statusOf(st))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Tenant < out[j].Tenant })
+	return out
+}
+
+// JobStatus estado de un tenant.
+func (s *Scheduler) JobStatus(tenant string) (JobStatus, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, ok := s.jobs[tenant]
+	if !ok {
+		return JobStatus{}, false
+	}
+	return statusOf(st), true
+}
+
+func statusOf(st *jobState) JobStatus {
+	runs := make([]Run, len(st.runs))
+	copy(runs, st.runs)
+	return JobStatus{
+		Tenant:   st.job.Tenant,
+		Schedule: st.job.Spec,
+		Running:  st.running,
+		NextRun:  st.nextRun,
+		Runs:     runs,
+	}
+}
+
+// configFile formato de SCHEDULER_CONFIG_FILE. La API key nunca va en el
+// archivo: api_key_env es el nombre de la variable de entorno que la contiene,
+// o tenant_id el tenant del registry cuya key se usa (en ese caso país y
+// destino son opcionales y salen del tenant).
+//
+//	{
+//	  "jitter": "30s",
+//	  "history_size": 20,
+//	  "tenants": [
+//	    {
+//	      "name": "tienda-co",
+//	      "schedule": "*/15 * * * *",
+//	      "api_key_env": "DROPI_KEY_TIENDA_CO",
+//	      "dropi_country_suffix": "co",
+//	      "webhook_suffix": "tienda/orders",
+//	      "time_zone": "America/Bogota",
+//	      "timeout": "2m",
+//	      "request": {"payload_version": "v2", "notify_new_orders": true}
+//	    }
+//	  ]
+//	}
+type configFile struct {
+	Jitter      string         `json:"jitter"`
+	HistorySize int            `json:"history_size"`
+	Tenants     []tenantConfig `json:"tenants"`
+}
+
+type tenantConfig struct {
+	Name               string          `json:"name"`
+	Schedule           string          `json:"schedule"`
+	APIKeyEnv          string          `json:"api_key_env"`
+	TenantID           string          `json:"tenant_id"`
+	DropiCountrySuffix string          `json:"dropi_country_suffix"`
+	WebhookSuffix      string          `json:"webhook_suffix"`
+	TimeZone           string          `json:"time_zone"`
+	Timeout            string          `json:"timeout"`
+	Jitter             string          `json:"jitter"`
+	Request            json.RawMessage `json:"request"`
+}
+
+// LoadJobs lee los jobs de un archivo de configuración.
+func LoadJobs(path string) ([]Job, int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error reading scheduler config: %w", err)
+	}
+
+	var file configFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, 0, fmt.Errorf("invalid scheduler config JSON: %w", err)
+	}
+
+	defaultJitter, err := parseOptionalDuration(file.Jitter)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid scheduler jitter: %w", err)
+	}
+
+	jobs := make([]Job, 0, len(file.Tenants))
+	seen := make(map[string]bool, len(file.Tenants))
+	for _, t := range file.Tenants {
+		job, err := t.job(defaultJitter)
+		if err != nil {
+			return nil, 0, fmt.Errorf("tenant %q: %w", t.Name, err)
+		}
+		if seen[job.Tenant] {
+			return nil, 0, fmt.Errorf("duplicate tenant %q", job.Tenant)
+		}
+		seen[job.Tenant] = true
+		jobs = append(jobs, job)
+	}
+	return jobs, file.HistorySize, nil
+}
+
+// TenantJobs jobs de los tenants del registry que tienen schedule: uno por
+// país y destino del tenant, llamado "tenant:país:webhook_suffix". Se omiten
+// los tenants que ya están en exclude (configurados en SCHEDULER_CONFIG_FILE)
+// y los que no tienen destinos. Un tenant con configuración inválida no
+// impide programar los demás: sus errores se retornan juntos con los jobs
+// válidos.
+func TenantJobs(tenants []tenant.Tenant, jitter time.Duration, exclude []Job) ([]Job, error) {
+	skip := make(map[string]bool, len(exclude))
+	for _, job := range exclude {
+		skip[job.Tenant] = true
+		if job.Request.TenantID != "" {
+			skip[job.Request.TenantID] = true
+		}
+	}
+
+	var jobs []Job
+	var errs []error
+nextTenant:
+	for _, t := range tenants {
+		if t.Schedule == "" || skip[t.ID] {
+			continue
+		}
+		if len(t.Destinations) == 0 {
+			slog.Warn("scheduler: tenant has a schedule but no destinations", "tenant", t.ID)
+			continue
+		}
+		for _, country := range t.CountrySuffixes {
+			for _, dest := range t.Destinations {
+				job, err := tenantConfig{
+					Name:               tenantJobName(t.ID, country, dest.WebhookSuffix),
+					Schedule:           t.Schedule,
+					TenantID:           t.ID,
+					DropiCountrySuffix: country,
+					WebhookSuffix:      dest.WebhookSuffix,
+					TimeZone:           t.TimeZone,
+				}.job(jitter)
+				if err != nil {
+					errs = append(errs, fmt.Errorf("tenant %q: %w", t.ID, err))
+					continue nextTenant
+				}
+				job.registry = true
+				jobs = append(jobs, job)
+			}
+		}
+	}
+	return jobs, errors.Join(errs...)
+}
+
+// TenantJitterFromEnv lee SCHEDULER_TENANT_JITTER (ej. "30s"), el jitter de
+// los jobs del registry; 0 si no está definido.
+func TenantJitterFromEnv() (time.Duration, error) {
+	d, err := parseOptionalDuration(os.Getenv("SCHEDULER_TENANT_JITTER"))
+	if err != nil {
+		return 0, fmt.Errorf("SCHEDULER_TENANT_JITTER: %w", err)
+	}
+	return d, nil
+}
+
+func tenantJobName(tenantID, country, webhookSuffix string) string {
+	return tenantID + ":" + country + ":" + webhookSuffix
+}
+
+func (t tenantConfig) job(defaultJitter time.Duration) (Job, error) {
+	if t.Name == "" {
+		return Job{}, fmt.Errorf("name is required")
+	}
+
+	schedule, err := ParseSchedule(t.Schedule)
+	if err != nil {
+		return Job{}, err
+	}
+
+	var req models.ProcessRequest
+	if len(t.Request) > 0 {
+		if err := json.Unmarshal(t.Request, &req); err != nil {
+			return Job{}, fmt.Errorf("invalid request template: %w", err)
+		}
+		if req.APIKey != "" {
+			return Job{}, fmt.Errorf("api_key is not allowed in the request template, use api_key_env")
+		}
+	}
+	switch {
+	case t.TenantID != "" && t.APIKeyEnv != "":
+		return Job{}, fmt.Errorf("use either api_key_env or tenant_id")
+	case t.TenantID != "":
+		// La key se descifra en cada ejecución: rotarla no requiere reiniciar
+		req.TenantID = t.TenantID
+	case t.APIKeyEnv == "":
+		return Job{}, fmt.Errorf("api_key_env or tenant_id is required")
+	default:
+		req.APIKey = models.Secret(os.Getenv(t.APIKeyEnv))
+		if req.APIKey == "" {
+			return Job{}, fmt.Errorf("environment variable %s is empty", t.APIKeyEnv)
+		}
+	}
+	req.DropiCountrySuffix = t.DropiCountrySuffix
+	req.WebhookSuffix = t.WebhookSuffix
+	if err := validateRequest(req); err != nil {
+		return Job{}, err
+	}
+
+	loc := time.UTC
+	if t.TimeZone != "" {
+		if loc, err = time.LoadLocation(t.TimeZone); err != nil {
+			return Job{}, fmt.Errorf("invalid time_zone: %w", err)
+		}
+	}
+
+	timeout, err := parseOptionalDuration(t.Timeout)
+	if err != nil {
+		return Job{}, fmt.Errorf("invalid timeout: %w", err)
+	}
+	jitter := defaultJitter
+	if t.Jitter != "" {
+		if jitter, err = parseOptionalDuration(t.Jitter); err != nil {
+			return Job{}, fmt.Errorf("invalid jitter: %w", err)
+		}
+	}
+
+	return Job{
+		Tenant:   t.Name,
+		Spec:     t.Schedule,
+		Schedule: schedule,
+		Jitter:   jitter,
+		Timeout:  timeout,
+		Location: loc,
+		Request:  req,
+	}, nil
+}
+
+// validateRequest mismas validaciones que /process.
+func validateRequest(req models.ProcessRequest) error {
+	v := validator.NewRequestValidator()
+	// Con tenant_id el país y el destino pueden quedar vacíos: los pone el tenant
+	if req.TenantID == "" || req.DropiCountrySuffix != "" {
+		if err := v.ValidateCountrySuffix(req.DropiCountrySuffix); err != nil {
+			return err
+		}
+	}
+	if req.TenantID == "" || req.WebhookSuffix != "" {
+		if err := v.ValidateWebhookSuffix(req.WebhookSuffix); err != nil {
+			return err
+		}
+	}
+	if !req.WebhookFormat.IsValid() {
+		return fmt.Errorf("invalid webhook_format %q", req.WebhookFormat)
+	}
+	if !req.PayloadVersion.IsValid() {
+		return fmt.Errorf("invalid payload_version %q", req.PayloadVersion)
+	}
+	return req.Filter.Validate()
+}
+
+func parseOptionalDuration(v string) (time.Duration, error) {
+	if v == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid duration %q", v)
+	}
+	return d, nil
+}
@@ -0,0 +1,141 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/juancollazo-ch/dropi-order-status-service/internal/models"
+	"github.com/juancollazo-ch/dropi-order-status-service/internal/service"
+	"github.com/juancollazo-ch/dropi-order-status-service/internal/tenant"
+)
+
+func registryTenant(id, schedule string) tenant.Tenant {
+	return tenant.Tenant{
+		ID:              id,
+		Name:            id,
+		CountrySuffixes: []string{"co", "mx"},
+		Destinations: []tenant.Destination{
+			{WebhookSuffix: id + "/orders"},
+			{WebhookSuffix: id + "/erp"},
+		},
+		Schedule: schedule,
+	}
+}
+
+func TestTenantJobsPerCountryAndDestination(t *testing.T) {
+	jobs, err := TenantJobs([]tenant.Tenant{registryTenant("acme", "*/15 * * * *")}, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(map[string]models.ProcessRequest, len(jobs))
+	for _, job := range jobs {
+		got[job.Tenant] = job.Request
+	}
+	want := map[string][2]string{
+		"acme:co:acme/orders": {"co", "acme/orders"},
+		"acme:co:acme/erp":    {"co", "acme/erp"},
+		"acme:mx:acme/orders": {"mx", "acme/orders"},
+		"acme:mx:acme/erp":    {"mx", "acme/erp"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d jobs, got %d: %v", len(want), len(got), got)
+	}
+	for name, w := range want {
+		req, ok := got[name]
+		if !ok {
+			t.Errorf("missing job %s", name)
+			continue
+		}
+		if req.TenantID != "acme" || req.DropiCountrySuffix != w[0] || req.WebhookSuffix != w[1] {
+			t.Errorf("%s: unexpected request %+v", name, req)
+		}
+	}
+}
+
+func TestTenantJobsSkipsAndReportsErrors(t *testing.T) {
+	noDest := registryTenant("nodest", "@hourly")
+	noDest.Destinations = nil
+	unscheduled := registryTenant("manual", "")
+	fromFile := registryTenant("file", "@hourly")
+	broken := registryTenant("broken", "not a cron")
+
+	exclude := []Job{{Tenant: "file-job", Request: models.ProcessRequest{TenantID: "file"}}}
+	tenants := []tenant.Tenant{noDest, unscheduled, fromFile, broken, registryTenant("ok", "@hourly")}
+
+	jobs, err := TenantJobs(tenants, 0, exclude)
+	if err == nil {
+		t.Fatal("expected an error for the invalid schedule")
+	}
+	if len(jobs) != 4 {
+		t.Fatalf("expected the 4 jobs of the valid tenant, got %d", len(jobs))
+	}
+	for _, job := range jobs {
+		if job.Request.TenantID != "ok" {
+			t.Errorf("unexpected job %s", job.Tenant)
+		}
+	}
+}
+
+func TestTenantJitterFromEnv(t *testing.T) {
+	t.Setenv("SCHEDULER_TENANT_JITTER", "")
+	if d, err := TenantJitterFromEnv(); err != nil || d != 0 {
+		t.Fatalf("empty: got %v, %v", d, err)
+	}
+	t.Setenv("SCHEDULER_TENANT_JITTER", "30s")
+	if d, err := TenantJitterFromEnv(); err != nil || d != 30*time.Second {
+		t.Fatalf("30s: got %v, %v", d, err)
+	}
+	t.Setenv("SCHEDULER_TENANT_JITTER", "soon")
+	if _, err := TenantJitterFromEnv(); err == nil {
+		t.Fatal("invalid jitter should fail")
+	}
+}
+
+func TestReplaceTenantJobs(t *testing.T) {
+	noop := func(context.Context, models.ProcessRequest) (*service.ProcessResult, error) { return nil, nil }
+	fileJob := Job{Tenant: "file-job", Spec: "@hourly", Schedule: everySchedule{interval: time.Hour}}
+	s := NewScheduler(noop, []Job{fileJob}, 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer func() {
+		cancel()
+		s.Wait()
+	}()
+	s.Start(ctx)
+
+	first, err := TenantJobs([]tenant.Tenant{registryTenant("acme", "@hourly")}, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.ReplaceTenantJobs(first)
+	if got := s.Len(); got != 5 {
+		t.Fatalf("expected file job plus 4 tenant jobs, got %d", got)
+	}
+
+	// Se quita un país y cambia el schedule: quedan 2 jobs del tenant
+	edited := registryTenant("acme", "*/5 * * * *")
+	edited.CountrySuffixes = []string{"co"}
+	second, err := TenantJobs([]tenant.Tenant{edited}, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.ReplaceTenantJobs(second)
+	if got := s.Len(); got != 3 {
+		t.Fatalf("expected file job plus 2 tenant jobs, got %d", got)
+	}
+	status, ok := s.JobStatus("acme:co:acme/orders")
+	if !ok || status.Schedule != "*/5 * * * *" {
+		t.Fatalf("job should use the edited schedule, got %+v", status)
+	}
+	if _, ok := s.JobStatus("acme:mx:acme/orders"); ok {
+		t.Fatal("job of the removed country should be gone")
+	}
+
+	// Borrar el tenant no toca los jobs del archivo
+	s.ReplaceTenantJobs(nil)
+	if _, ok := s.JobStatus("file-job"); !ok || s.Len() != 1 {
+		t.Fatalf("only the file job should remain, got %d jobs", s.Len())
+	}
+}
@@ -0,0 +1,127 @@
+package schema
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Draft versión de JSON Schema generada.
+const Draft = "https://json-schema.org/draft/2020-12/schema"
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Generate JSON Schema del tipo a partir de sus tags json, el mismo contrato
+// que produce encoding/json:
+//   - los campos sin omitempty son required
+//   - los punteros admiten null
+//   - los campos con omitempty pueden faltar
+//
+// Como el schema sale del tipo Go, cualquier cambio de forma del payload se
+// refleja en el schema publicado.
+func Generate(t reflect.Type, id, title string) map[string]interface{} {
+	s := typeSchema(t)
+	s["$schema"] = Draft
+	if id != "" {
+		s["$id"] = id
+	}
+	if title != "" {
+		s["title"] = title
+	}
+	return s
+}
+
+func typeSchema(t reflect.Type) map[string]interface{} {
+	if t.Kind() == reflect.Ptr {
+		s := typeSchema(t.Elem())
+		return nullable(s)
+	}
+
+	if t == timeType {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "contentEncoding": "base64"}
+		}
+		return map[string]interface{}{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": typeSchema(t.Elem())}
+	case reflect.Struct:
+		return structSchema(t)
+	}
+
+	// interface{} y otros: cualquier valor
+	return map[string]interface{}{}
+}
+
+func structSchema(t reflect.Type) map[string]interface{} {
+	properties := make(map[string]interface{})
+	required := make([]string, 0)
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, omitempty, skip := jsonName(field)
+		if skip {
+			continue
+		}
+
+		properties[name] = typeSchema(field.Type)
+		if !omitempty {
+			required = append(required, name)
+		}
+	}
+
+	return map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": false,
+	}
+}
+
+// jsonName nombre del campo en JSON según su tag.
+func jsonName(field reflect.StructField) (name string, omitempty, skip bool) {
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false, true
+	}
+
+	parts := strings.Split(tag, ",")
+	name = parts[0]
+	if name == "" {
+		name = field.Name
+	}
+	for _, opt := range parts[1:] {
+		if opt == "omitempty" {
+			omitempty = true
+		}
+	}
+	return name, omitempty, false
+}
+
+// nullable agrega null a los tipos admitidos.
+func nullable(s map[string]interface{}) map[string]interface{} {
+	switch typ := s["type"].(type) {
+	case string:
+		s["type"] = []string{typ, "null"}
+	case []string:
+		s["type"] = append(typ, "null")
+	}
+	return s
+}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/juancollazo-ch/dropi-order-status-service/internal/models"
+)
+
+// defaultBatchConcurrency entradas de un batch procesadas en paralelo
+const defaultBatchConcurrency = 4
+
+// batchConcurrencyFromEnv BATCH_CONCURRENCY o el default.
+func batchConcurrencyFromEnv() int {
+	if n, err := strconv.Atoi(os.Getenv("BATCH_CONCURRENCY")); err == nil && n > 0 {
+		return n
+	}
+	return defaultBatchConcurrency
+}
+
+// BatchEntryResult resultado de una entrada; Result es nil si falló.
+type BatchEntryResult struct {
+	Index              int            `json:"index"`
+	TenantID           string         `json:"tenant_id,omitempty"`
+	DropiCountrySuffix string         `json:"dropi_country_suffix"`
+	WebhookSuffix      string         `json:"webhook_suffix"`
+	Date               string         `json:"date"`
+	Error              string         `json:"error,omitempty"`
+	Result             *ProcessResult `json:"result,omitempty"`
+}
+
+// BatchSummary totales de todas las entradas.
+type BatchSummary struct {
+	Entries          int  `json:"entries"`
+	Succeeded        int  `json:"succeeded"`
+	Failed           int  `json:"failed"`
+	TotalOrders      int  `json:"total_orders"`
+	OrdersProcessed  int  `json:"orders_processed"`
+	ChangesDetected  int  `json:"changes_detected"`
+	WebhooksQueued   int  `json:"webhooks_queued"`
+	WebhooksRejected int  `json:"webhooks_rejected,omitempty"`
+	PartialTimeout   bool `json:"partial_timeout,omitempty"`
+	QueueSaturated   bool `json:"queue_saturated,omitempty"`
+}
+
+type BatchResult struct {
+	Summary BatchSummary       `json:"summary"`
+	Results []BatchEntryResult `json:"results"` // en el orden del request
+}
+
+// BatchConcurrency entradas que un batch procesa a la vez: las pedidas por
+// el request, sin superar BATCH_CONCURRENCY (0 = BATCH_CONCURRENCY).
+func (s *OrderService) BatchConcurrency(requested int) int {
+	if requested > 0 && requested < s.batchConcurrency {
+		return requested
+	}
+	return s.batchConcurrency
+}
+
+// HandleBatch procesa las entradas (ya validadas) en paralelo, como máximo
+// BatchConcurrency(concurrency) a la vez. Cada entrada tiene su propio
+// deadline de entryTimeout desde que empieza (0 = solo el de ctx), así las
+// que esperan turno no heredan el tiempo ya consumido. El error de una
+// entrada no detiene las demás.
+func (s *OrderService) HandleBatch(
+	ctx context.Context,
+	entries []models.ProcessRequest,
+	concurrency int,
+	entryTimeout time.Duration,
+) *BatchResult {
+	return s.handleBatch(ctx, entries, concurrency, entryTimeout, s.HandleOrderRequest)
+}
+
+func (s *OrderService) handleBatch(
+	ctx context.Context,
+	entries []models.ProcessRequest,
+	concurrency int,
+	entryTimeout time.Duration,
+	process func(context.Context, models.ProcessRequest) (*ProcessResult, error),
+) *BatchResult {
+
+	limit := s.BatchConcurrency(concurrency)
+
+	results := make([]BatchEntryResult, len(entries))
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+
+	slog.Info("batch started", "entries", len(entries), "concurrency", limit)
+
+	for i, req := range entries {
+		results[i] = BatchEntryResult{
+			Index:              i,
+			TenantID:           req.TenantID,
+			DropiCountrySuffix: req.DropiCountrySuffix,
+			WebhookSuffix:      req.WebhookSuffix,
+			Date:               req.Date,
+		}
+
+		wg.Add(1)
+		go func(i int, req models.ProcessRequest) {
+			defer wg.Done()
+
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				results[i].Error = ctx.Err().Error()
+				return
+			}
+			defer func() { <-sem }()
+
+			entryCtx := ctx
+			if entryTimeout > 0 {
+				var cancel context.CancelFunc
+				entryCtx, cancel = context.WithTimeout(ctx, entryTimeout)
+				defer cancel()
+			}
+
+			result, err := process(entryCtx, req)
+			if err != nil {
+				results[i].Error = err.Error()
+				return
+			}
+			results[i].Result = result
+		}(i, req)
+	}
+	wg.Wait()
+
+	batch := &BatchResult{
+		Summary: BatchSummary{Entries: len(entries)},
+		Results: results,
+	}
+	for _, r := range results {
+		if r.Result == nil {
+			batch.Summary.Failed++
+			continue
+		}
+		batch.Summary.Succeeded++
+		batch.Summary.TotalOrders += r.Result.TotalOrders
+		batch.Summary.OrdersProcessed += r.Result.OrdersProcessed
+		batch.Summary.ChangesDetected += r.Result.ChangesDetected
+		batch.Summary.WebhooksQueued += r.Result.WebhooksQueued
+		batch.Summary.WebhooksRejected += r.Result.WebhooksRejected
+		batch.Summary.PartialTimeout = batch.Summary.PartialTimeout || r.Result.PartialTimeout
+		batch.Summary.QueueSaturated = batch.Summary.QueueSaturated || r.Result.QueueSaturated
+	}
+
+	slog.Info("batch finished",
+		"entries", batch.Summary.Entries,
+		"succeeded", batch.Summary.Succeeded,
+		"failed", batch.Summary.Failed,
+		"webhooks_queued", batch.Summary.WebhooksQueued,
+	)
+	return batch
+}
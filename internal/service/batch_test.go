@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/juancollazo-ch/dropi-order-status-service/internal/models"
+)
+
+func TestBatchEntriesHaveTheirOwnDeadline(t *testing.T) {
+	s := NewOrderService(nil, nil)
+	entries := []models.ProcessRequest{{Date: "2024-01-01"}, {Date: "2024-01-02"}, {Date: "2024-01-03"}, {Date: "2024-01-04"}}
+	const entryTimeout = 80 * time.Millisecond
+
+	var mu sync.Mutex
+	budgets := make(map[string]time.Duration)
+	process := func(ctx context.Context, req models.ProcessRequest) (*ProcessResult, error) {
+		deadline, ok := ctx.Deadline()
+		if !ok {
+			return nil, errors.New("entry without deadline")
+		}
+		mu.Lock()
+		budgets[req.Date] = time.Until(deadline)
+		mu.Unlock()
+
+		// La segunda entrada agota su deadline; las siguientes no lo heredan
+		if req.Date == "2024-01-02" {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		time.Sleep(20 * time.Millisecond)
+		return &ProcessResult{TotalOrders: 1}, nil
+	}
+
+	// Concurrencia 1: el batch completo dura más que el deadline de una entrada
+	result := s.handleBatch(context.Background(), entries, 1, entryTimeout, process)
+
+	if result.Summary.Succeeded != 3 || result.Summary.Failed != 1 {
+		t.Fatalf("expected 3 succeeded and 1 failed, got %+v", result.Summary)
+	}
+	if result.Results[1].Error != context.DeadlineExceeded.Error() {
+		t.Fatalf("entry 1 error = %q, want deadline exceeded", result.Results[1].Error)
+	}
+	for date, budget := range budgets {
+		if budget < entryTimeout-20*time.Millisecond || budget > entryTimeout {
+			t.Errorf("entry %s started with %v left, want about %v", date, budget, entryTimeout)
+		}
+	}
+}
+
+func TestBatchConcurrency(t *testing.T) {
+	t.Setenv("BATCH_CONCURRENCY", "4")
+	s := NewOrderService(nil, nil)
+
+	tests := map[int]int{0: 4, 2: 2, 4: 4, 10: 4}
+	for requested, want := range tests {
+		if got := s.BatchConcurrency(requested); got != want {
+			t.Errorf("BatchConcurrency(%d) = %d, want %d", requested, got, want)
+		}
+	}
+}
@@ -7,31 +7,74 @@ import (
 
 	"github.com/juancollazo-ch/dropi-order-status-service/internal/api"
 	"github.com/juancollazo-ch/dropi-order-status-service/internal/compare"
+	"github.com/juancollazo-ch/dropi-order-status-service/internal/models"
+	"github.com/juancollazo-ch/dropi-order-status-service/internal/sla"
+	"github.com/juancollazo-ch/dropi-order-status-service/internal/state"
+	"github.com/juancollazo-ch/dropi-order-status-service/internal/tenant"
 	"github.com/juancollazo-ch/dropi-order-status-service/internal/worker"
 )
 
 type OrderService struct {
 	client     *api.DropiClient
 	workerPool *worker.WorkerPool
+	comparator *compare.Comparator
+	sla        *sla.Monitor
+	tenants    *tenant.Registry
+
+	batchConcurrency int
 }
 
 func NewOrderService(client *api.DropiClient, pool *worker.WorkerPool) *OrderService {
 	return &OrderService{
 		client:     client,
 		workerPool: pool,
+		comparator: compare.NewComparator(nil),
+
+		batchConcurrency: batchConcurrencyFromEnv(),
 	}
 }
 
+// WithSLA habilita el monitoreo de tiempo en status de las órdenes consultadas.
+func (s *OrderService) WithSLA(m *sla.Monitor) *OrderService {
+	s.sla = m
+	return s
+}
+
+// WithComparator reemplaza el comparador (por ejemplo con alias de status por país).
+func (s *OrderService) WithComparator(c *compare.Comparator) *OrderService {
+	s.comparator = c
+	return s
+}
+
 type ProcessResult struct {
 	TotalOrders      int           `json:"total_orders"`
 	OrdersProcessed  int           `json:"orders_processed"`
 	ChangesDetected  int           `json:"changes_detected"`
-	WebhooksQueued   int           `json:"webhooks_queued"`   // Webhooks encolados
-	WebhooksPending  int           `json:"webhooks_pending"`  // Webhooks aún procesándose
+	WebhooksQueued   int           `json:"webhooks_queued"`  // Webhooks encolados
+	WebhooksPending  int           `json:"webhooks_pending"` // Webhooks aún procesándose
 	OrdersSkipped    int           `json:"orders_skipped"`
 	Errors           []string      `json:"errors,omitempty"`
 	Details          []OrderStatus `json:"details"`
-	PartialTimeout   bool          `json:"partial_timeout,omitempty"` // Indica si hubo timeout parcial
+	PartialTimeout   bool          `json:"partial_timeout,omitempty"`   // Indica si hubo timeout parcial
+	WebhooksRejected int           `json:"webhooks_rejected,omitempty"` // Webhooks no encolados por cola llena
+	QueueSaturated   bool          `json:"queue_saturated,omitempty"`   // La cola de webhooks está llena
+	QueueDepth       int           `json:"queue_depth"`                 // Tareas en cola al terminar
+
+	WebhooksSuppressed int            `json:"webhooks_suppressed,omitempty"`  // Suprimidos por reglas de transición
+	WebhooksFiltered   int            `json:"webhooks_filtered,omitempty"`    // Descartados por los filtros del request
+	OrdersWithWarnings int            `json:"orders_with_warnings,omitempty"` // Órdenes con montos o fechas no interpretables
+	NewOrders          int            `json:"new_orders,omitempty"`           // Órdenes vistas por primera vez
+	SLABreaches        int            `json:"sla_breaches,omitempty"`         // Órdenes que incumplen su SLA
+	FlaggedOrders      []FlaggedOrder `json:"flagged_orders,omitempty"`       // Transiciones sospechosas
+}
+
+// FlaggedOrder orden con una transición de status que no es normal
+type FlaggedOrder struct {
+	OrderID    string `json:"order_id"`
+	From       string `json:"from"`
+	To         string `json:"to"`
+	Transition string `json:"transition"` // regression | unknown
+	Action     string `json:"action"`     // deliver | suppress | escalate
 }
 
 type OrderStatus struct {
@@ -39,7 +82,18 @@ type OrderStatus struct {
 	ProductNames  []string `json:"product_names"`
 	PreviousState string   `json:"previous_state"`
 	CurrentState  string   `json:"current_state"`
+	PreviousCode  string   `json:"previous_status_code"`
+	CurrentCode   string   `json:"current_status_code"`
 	Changed       bool     `json:"changed"`
+	Transition    string   `json:"transition,omitempty"`
+	Filtered      bool     `json:"filtered,omitempty"`
+	NewOrder      bool     `json:"new_order,omitempty"`
+	SLABreached   bool     `json:"sla_breached,omitempty"`
+
+	Changes  []models.FieldChange  `json:"changes,omitempty"`  // campos que cambiaron
+	Warnings []models.OrderWarning `json:"warnings,omitempty"` // montos o fechas que no se pudieron interpretar
+
+	HistoryAnomalies []models.HistoryAnomaly `json:"history_anomalies,omitempty"` // history desordenado o con duplicados
 }
 
 // ---------------------------------------------------------
@@ -47,11 +101,46 @@ type OrderStatus struct {
 // ---------------------------------------------------------
 func (s *OrderService) HandleOrderRequest(
 	ctx context.Context,
-	apiKey string,
-	date string,
-	countrySuffix string,
-	webhookSuffix string,
+	req models.ProcessRequest,
+) (*ProcessResult, error) {
+	return s.processOrders(ctx, req, nil)
+}
+
+// HandleOrderRequestStream igual que HandleOrderRequest, pero cada detalle se
+// entrega a onDetail apenas se compara la orden en lugar de acumularse en
+// Details: el resultado final trae solo los totales.
+func (s *OrderService) HandleOrderRequestStream(
+	ctx context.Context,
+	req models.ProcessRequest,
+	onDetail func(OrderStatus),
 ) (*ProcessResult, error) {
+	return s.processOrders(ctx, req, onDetail)
+}
+
+func (s *OrderService) processOrders(
+	ctx context.Context,
+	req models.ProcessRequest,
+	onDetail func(OrderStatus),
+) (*ProcessResult, error) {
+
+	// Requests del scheduler con tenant_id: la key se descifra en cada
+	// ejecución y el request resuelto se valida como en /process
+	if req.TenantID != "" && req.APIKey == "" {
+		resolved, err := s.ResolveRequest(req)
+		if err != nil {
+			return nil, err
+		}
+		if err := validateResolved(resolved); err != nil {
+			return nil, fmt.Errorf("tenant %s: %w", req.TenantID, err)
+		}
+		req = resolved
+	}
+
+	apiKey := req.APIKey.Reveal()
+	date := req.Date
+	countrySuffix := req.DropiCountrySuffix
+	webhookSuffix := req.WebhookSuffix
+	scope := state.Scope{TenantID: req.TenantID, Country: countrySuffix, Destination: webhookSuffix}
 
 	const resultNumber = 50 // máximo permitido
 
@@ -80,9 +169,28 @@ func (s *OrderService) HandleOrderRequest(
 		Errors:      []string{},
 	}
 
+	// La profundidad de la cola se reporta en todas las salidas, también
+	// con resultado vacío o timeout parcial
+	defer func() {
+		result.QueueDepth = s.workerPool.QueueDepth()
+	}()
+
+	// Los snapshots de las órdenes atendidas se persisten al terminar
+	defer func() {
+		if err := s.comparator.Flush(); err != nil {
+			logger.Error("error saving order snapshots", "error", err)
+		}
+		if s.sla != nil {
+			if err := s.sla.Flush(); err != nil {
+				logger.Error("error saving SLA state", "error", err)
+			}
+		}
+	}()
+
 	// Si no hay órdenes, retornar resultado vacío (no es un error)
 	if len(orders) == 0 {
 		logger.Info("no orders found for this date", "date", date)
+		s.markSeeded(scope, logger)
 		return result, nil
 	}
 
@@ -107,7 +215,7 @@ func (s *OrderService) HandleOrderRequest(
 		result.OrdersProcessed++
 
 		// 2) Comparar estados
-		compareResult, err := compare.CompareOrderStatus(order, logger)
+		compareResult, err := s.comparator.Compare(order, scope, logger)
 		if err != nil {
 			result.OrdersSkipped++
 			errMsg := fmt.Sprintf("Order %d: %s", order.ID, err.Error())
@@ -126,19 +234,202 @@ func (s *OrderService) HandleOrderRequest(
 			ProductNames:  compareResult.ProductNames,
 			PreviousState: compareResult.OldStatus,
 			CurrentState:  compareResult.NewStatus,
+			PreviousCode:  string(compareResult.OldCode),
+			CurrentCode:   string(compareResult.NewCode),
 			Changed:       compareResult.Changed,
+			NewOrder:      compareResult.NewOrder,
+			Transition:    string(compareResult.Transition),
+			Changes:       compareResult.FieldChanges,
+			Warnings:      order.Warnings(countrySuffix),
+
+			HistoryAnomalies: compareResult.Anomalies,
+		}
+		if len(statusInfo.Warnings) > 0 {
+			result.OrdersWithWarnings++
+			logger.Warn("order has unparseable fields",
+				"order_id", order.ID,
+				"warnings", len(statusInfo.Warnings),
+			)
+		}
+		// Filtros de suscripción: cambios que el receptor no quiere recibir
+		if compareResult.Changed && !s.filterAllows(req, order, compareResult.OldCode, compareResult.NewCode) {
+			statusInfo.Filtered = true
+		}
+
+		// SLA: tiempo en el status actual
+		if s.sla != nil {
+			if breach := s.sla.Evaluate(order, scope); breach != nil {
+				result.SLABreaches++
+				statusInfo.SLABreached = true
+				s.notifySLABreach(result, req, order, scope, breach, logger)
+			}
+		}
+
+		if onDetail != nil {
+			onDetail(statusInfo)
+		} else {
+			result.Details = append(result.Details, statusInfo)
+		}
+
+		// Orden nueva: evento order.created si el request lo pidió
+		if compareResult.NewOrder {
+			result.NewOrders++
+			if req.NotifyNewOrders {
+				if !s.filterAllows(req, order, "", compareResult.NewCode) {
+					result.WebhooksFiltered++
+				} else if !s.enqueueWebhook(result, worker.WorkerTask{
+					Order:         *order,
+					WebhookSuffix: webhookSuffix,
+					CountrySuffix: countrySuffix,
+					Format:        req.WebhookFormat,
+					Event:         models.EventOrderCreated,
+				}, logger) {
+					// Sin snapshot la orden se vuelve a ver como nueva en la próxima ejecución
+					continue
+				}
+			}
 		}
-		result.Details = append(result.Details, statusInfo)
 
 		// 3) Si cambió → Encolar webhook (ACTUALIZADO)
 		if compareResult.Changed {
 			result.ChangesDetected++
 
-			s.workerPool.Enqueue(*order, webhookSuffix)
-			result.WebhooksQueued++
-			result.WebhooksPending++
+			// Transiciones sospechosas: se reportan y, según las reglas, se escalan o suprimen
+			if compareResult.StatusChanged && compareResult.Transition != compare.TransitionNormal {
+				result.FlaggedOrders = append(result.FlaggedOrders, FlaggedOrder{
+					OrderID:    fmt.Sprintf("%d", compareResult.OrderID),
+					From:       string(compareResult.OldCode),
+					To:         string(compareResult.NewCode),
+					Transition: string(compareResult.Transition),
+					Action:     string(compareResult.Action),
+				})
+			}
+			if statusInfo.Filtered {
+				result.WebhooksFiltered++
+				logger.Info("webhook filtered by subscription",
+					"order_id", order.ID,
+					"from", compareResult.OldCode,
+					"to", compareResult.NewCode,
+				)
+				s.commitSnapshot(order, scope, logger)
+				continue
+			}
+			if compareResult.Action == compare.ActionSuppress {
+				result.WebhooksSuppressed++
+				logger.Warn("webhook suppressed by transition rules",
+					"order_id", order.ID,
+					"transition", compareResult.Transition,
+				)
+				s.commitSnapshot(order, scope, logger)
+				continue
+			}
+
+			queued := s.enqueueWebhook(result, worker.WorkerTask{
+				Order:         *order,
+				WebhookSuffix: webhookSuffix,
+				CountrySuffix: countrySuffix,
+				Format:        req.WebhookFormat,
+				Options:       s.payloadOptions(req),
+				Version:       req.PayloadVersion,
+				Escalated:     compareResult.Action == compare.ActionEscalate,
+			}, logger)
+			if !queued {
+				// Sin guardar el snapshot el cambio se vuelve a detectar en la próxima ejecución
+				continue
+			}
 		}
+
+		s.commitSnapshot(order, scope, logger)
 	}
 
+	// Con webhooks rechazados quedan órdenes sin snapshot: sembrar ahora las
+	// anunciaría como nuevas en la próxima ejecución
+	if result.WebhooksRejected == 0 {
+		s.markSeeded(scope, logger)
+	}
 	return result, nil
 }
+
+// notifySLABreach encola el evento order.sla_breached una sola vez por
+// incumplimiento y destino, si el request lo pidió.
+func (s *OrderService) notifySLABreach(result *ProcessResult, req models.ProcessRequest, order *models.DropiOrder, scope state.Scope, breach *models.SLABreach, logger *slog.Logger) {
+	logger.Warn("order SLA breached",
+		"order_id", order.ID,
+		"status", breach.Status,
+		"in_status", breach.InStatus,
+		"threshold", breach.Threshold,
+	)
+	if breach.Notified || !req.NotifySLABreaches {
+		return
+	}
+
+	task := worker.WorkerTask{
+		Order:         *order,
+		WebhookSuffix: req.WebhookSuffix,
+		CountrySuffix: req.DropiCountrySuffix,
+		Format:        req.WebhookFormat,
+		Event:         models.EventSLABreached,
+		Escalated:     true,
+	}
+	task.Order.SLABreach = breach
+	if s.enqueueWebhook(result, task, logger) {
+		s.sla.MarkNotified(scope, breach)
+	}
+}
+
+// payloadOptions bloques opt-in del request con los alias de status del
+// comparador, para que el history del webhook use los mismos códigos.
+func (s *OrderService) payloadOptions(req models.ProcessRequest) models.PayloadOptions {
+	opts := req.PayloadOptions
+	opts.Normalizer = s.comparator.Normalizer()
+	opts.Country = req.DropiCountrySuffix
+	return opts
+}
+
+// filterAllows aplica el filtro del request y el del destino del tenant,
+// normalizando sus status con los alias del país.
+func (s *OrderService) filterAllows(req models.ProcessRequest, order *models.DropiOrder, from, to models.OrderStatus) bool {
+	n := s.comparator.Normalizer()
+	country := req.DropiCountrySuffix
+	return req.Filter.Allows(n, country, order, from, to) &&
+		req.DestinationFilter.Allows(n, country, order, from, to)
+}
+
+// commitSnapshot guarda los valores actuales de la orden para la próxima comparación.
+func (s *OrderService) commitSnapshot(order *models.DropiOrder, scope state.Scope, logger *slog.Logger) {
+	if err := s.comparator.Commit(order, scope); err != nil {
+		logger.Warn("error saving order snapshot",
+			"order_id", order.ID,
+			"error", err,
+		)
+	}
+}
+
+// markSeeded marca el scope como sembrado al terminar una ejecución completa
+// (sin timeout parcial ni órdenes pendientes): desde la siguiente se emite
+// order.created.
+func (s *OrderService) markSeeded(scope state.Scope, logger *slog.Logger) {
+	if err := s.comparator.MarkSeeded(scope); err != nil {
+		logger.Warn("error marking snapshot scope as seeded", "error", err)
+	}
+}
+
+// enqueueWebhook encola la tarea sin bloquear y actualiza los contadores.
+// Con la cola llena la orden queda reportada como rechazada y retorna false.
+func (s *OrderService) enqueueWebhook(result *ProcessResult, task worker.WorkerTask, logger *slog.Logger) bool {
+	if err := s.workerPool.TryEnqueue(task); err != nil {
+		result.WebhooksRejected++
+		result.QueueSaturated = true
+		result.Errors = append(result.Errors, fmt.Sprintf("Order %d: %s", task.Order.ID, err.Error()))
+
+		logger.Warn("webhook rejected (queue full)",
+			"order_id", task.Order.ID,
+			"queue_depth", s.workerPool.QueueDepth(),
+		)
+		return false
+	}
+
+	result.WebhooksQueued++
+	result.WebhooksPending++
+	return true
+}
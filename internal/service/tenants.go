@@ -0,0 +1,108 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/juancollazo-ch/dropi-order-status-service/internal/models"
+	"github.com/juancollazo-ch/dropi-order-status-service/internal/tenant"
+	"github.com/juancollazo-ch/dropi-order-status-service/internal/validator"
+)
+
+// ErrTenantsDisabled el request trae tenant_id pero no hay registry configurado.
+var ErrTenantsDisabled = errors.New("tenant_id is not supported: tenant registry is disabled")
+
+// WithTenants habilita requests con tenant_id en lugar de api_key.
+func (s *OrderService) WithTenants(r *tenant.Registry) *OrderService {
+	s.tenants = r
+	return s
+}
+
+// ResolveRequest completa un request con tenant_id: la integration key sale
+// del registry y el país y el destino, si no vienen, del tenant (un tenant
+// sin destinos requiere webhook_suffix en el request). Sin tenant_id retorna
+// el request sin cambios.
+func (s *OrderService) ResolveRequest(req models.ProcessRequest) (models.ProcessRequest, error) {
+	if req.TenantID == "" {
+		return req, nil
+	}
+	if s.tenants == nil {
+		return req, ErrTenantsDisabled
+	}
+
+	t, err := s.tenants.Get(req.TenantID)
+	if err != nil {
+		return req, err
+	}
+
+	if req.DropiCountrySuffix == "" {
+		req.DropiCountrySuffix = t.CountrySuffixes[0]
+	} else if !containsFold(t.CountrySuffixes, req.DropiCountrySuffix) {
+		return req, fmt.Errorf("dropi_country_suffix %q is not enabled for tenant %s", req.DropiCountrySuffix, t.ID)
+	}
+
+	if len(t.Destinations) > 0 {
+		dest, ok := t.Destinations[0], req.WebhookSuffix == ""
+		for _, d := range t.Destinations {
+			if d.WebhookSuffix == req.WebhookSuffix {
+				dest, ok = d, true
+				break
+			}
+		}
+		if !ok {
+			return req, fmt.Errorf("webhook_suffix %q is not a destination of tenant %s", req.WebhookSuffix, t.ID)
+		}
+		req.WebhookSuffix = dest.WebhookSuffix
+		if req.WebhookFormat == "" {
+			req.WebhookFormat = dest.WebhookFormat
+		}
+		if req.PayloadVersion == "" {
+			req.PayloadVersion = dest.PayloadVersion
+		}
+		req.DestinationFilter = dest.Filter
+	} else if req.WebhookSuffix == "" {
+		return req, fmt.Errorf("%w: %s", tenant.ErrNoDestinations, t.ID)
+	}
+
+	key, err := s.tenants.IntegrationKey(t.ID)
+	if err != nil {
+		return req, err
+	}
+	req.APIKey = models.Secret(key)
+	return req, nil
+}
+
+// validateResolved valida un request resuelto desde el registry con las mismas
+// reglas que /process, antes de consultar Dropi: sin destino los webhooks
+// fallarían al armar la URL y se reintentarían por horas.
+func validateResolved(req models.ProcessRequest) error {
+	if req.APIKey == "" {
+		return tenant.ErrNoKey
+	}
+	if !validator.IsValidDate(req.Date) {
+		return fmt.Errorf("date must be in format YYYY-MM-DD")
+	}
+	if err := validator.NewRequestValidator().ValidateRequest(&req); err != nil {
+		return err
+	}
+	if !req.WebhookFormat.IsValid() {
+		return fmt.Errorf("invalid webhook_format %q", req.WebhookFormat)
+	}
+	if !req.PayloadVersion.IsValid() {
+		return fmt.Errorf("invalid payload_version %q", req.PayloadVersion)
+	}
+	if err := req.Filter.Validate(); err != nil {
+		return err
+	}
+	return req.DestinationFilter.Validate()
+}
+
+func containsFold(list []string, v string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, v) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/juancollazo-ch/dropi-order-status-service/internal/models"
+	"github.com/juancollazo-ch/dropi-order-status-service/internal/tenant"
+)
+
+// tenantService servicio sin cliente de Dropi: si algún caso llegara a
+// consultar órdenes el test entraría en pánico.
+func tenantService(t *testing.T, tenants ...tenant.Input) *OrderService {
+	t.Helper()
+	keyPath := filepath.Join(t.TempDir(), "kek")
+	if err := os.WriteFile(keyPath, []byte(hex.EncodeToString(bytes.Repeat([]byte{1}, 32))), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	keys, err := tenant.NewLocalKeyProvider(keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry, err := tenant.NewRegistry("", keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, in := range tenants {
+		if _, err := registry.Create(in); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return NewOrderService(nil, nil).WithTenants(registry)
+}
+
+func tenantInput(id string, destinations ...tenant.Destination) tenant.Input {
+	name, key := id, "key-"+id
+	return tenant.Input{
+		ID:              id,
+		Name:            &name,
+		CountrySuffixes: []string{"co", "mx"},
+		Destinations:    destinations,
+		IntegrationKey:  &key,
+	}
+}
+
+func TestResolveRequest(t *testing.T) {
+	s := tenantService(t, tenantInput("acme",
+		tenant.Destination{WebhookSuffix: "acme/orders"},
+		tenant.Destination{WebhookSuffix: "acme/erp", WebhookFormat: models.WebhookFormatCloudEventsBinary, PayloadVersion: models.PayloadV2},
+	))
+
+	req, err := s.ResolveRequest(models.ProcessRequest{TenantID: "acme", DropiCountrySuffix: "mx", WebhookSuffix: "acme/erp"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.APIKey.Reveal() != "key-acme" || req.PayloadVersion != models.PayloadV2 || req.WebhookFormat != models.WebhookFormatCloudEventsBinary {
+		t.Fatalf("unexpected resolved request %+v", req)
+	}
+
+	if _, err := s.ResolveRequest(models.ProcessRequest{TenantID: "acme", DropiCountrySuffix: "cl"}); err == nil {
+		t.Fatal("country not enabled for the tenant should fail")
+	}
+	if _, err := s.ResolveRequest(models.ProcessRequest{TenantID: "acme", WebhookSuffix: "other/hook"}); err == nil {
+		t.Fatal("unknown destination should fail")
+	}
+}
+
+func TestProcessTenantWithoutDestinations(t *testing.T) {
+	s := tenantService(t, tenantInput("nodest"))
+
+	_, err := s.HandleOrderRequest(context.Background(), models.ProcessRequest{TenantID: "nodest", Date: "2024-01-10"})
+	if !errors.Is(err, tenant.ErrNoDestinations) {
+		t.Fatalf("expected ErrNoDestinations, got %v", err)
+	}
+}
+
+func TestProcessTenantValidatesResolvedRequest(t *testing.T) {
+	s := tenantService(t, tenantInput("acme", tenant.Destination{WebhookSuffix: "acme/orders"}))
+
+	tests := []struct {
+		name string
+		req  models.ProcessRequest
+	}{
+		{"missing date", models.ProcessRequest{TenantID: "acme"}},
+		{"bad date", models.ProcessRequest{TenantID: "acme", Date: "10/01/2024"}},
+		{"bad format", models.ProcessRequest{TenantID: "acme", Date: "2024-01-10", WebhookFormat: "xml"}},
+		{"bad version", models.ProcessRequest{TenantID: "acme", Date: "2024-01-10", PayloadVersion: "v9"}},
+	}
+	for _, tt := range tests {
+		if _, err := s.HandleOrderRequest(context.Background(), tt.req); err == nil {
+			t.Errorf("%s: expected a validation error before fetching orders", tt.name)
+		}
+	}
+}
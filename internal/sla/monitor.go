@@ -0,0 +1,259 @@
+package sla
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/juancollazo-ch/dropi-order-status-service/internal/models"
+	"github.com/juancollazo-ch/dropi-order-status-service/internal/state"
+)
+
+// defaultRetention tiempo que un incumplimiento sigue listado sin volver a ver la orden
+const defaultRetention = 7 * 24 * time.Hour
+
+// Rule tiempo máximo en un status; con ShippingCompany vacío aplica a todas
+// las transportadoras. La regla de una transportadora tiene prioridad.
+type Rule struct {
+	Status          models.OrderStatus
+	ShippingCompany string
+	Max             time.Duration
+}
+
+// DefaultRules guía generada sin despachar en 72h y novedad sin resolver en 48h.
+func DefaultRules() []Rule {
+	return []Rule{
+		{Status: models.StatusGuiaGenerada, Max: 72 * time.Hour},
+		{Status: models.StatusNovedad, Max: 48 * time.Hour},
+	}
+}
+
+// rulesFile formato de SLA_RULES_FILE:
+//
+//	{
+//	  "rules": [
+//	    {"status": "GUIA_GENERADA", "max": "72h"},
+//	    {"status": "EN_TRANSITO", "shipping_company": "SERVIENTREGA", "max": "120h"}
+//	  ],
+//	  "retention": "168h"
+//	}
+type rulesFile struct {
+	Rules []struct {
+		Status          string `json:"status"`
+		ShippingCompany string `json:"shipping_company"`
+		Max             string `json:"max"`
+	} `json:"rules"`
+	Retention string `json:"retention"`
+}
+
+// Monitor evalúa el tiempo en el status de las órdenes consultadas y guarda
+// los incumplimientos vigentes. Un incumplimiento es uno por orden; cada
+// destino lleva su propia marca de notificado.
+type Monitor struct {
+	normalizer *models.StatusNormalizer
+	rules      map[models.OrderStatus]map[string]time.Duration
+	retention  time.Duration
+	now        func() time.Time
+	notified   *notifiedStore
+
+	mu       sync.Mutex
+	breaches map[string]*models.SLABreach
+}
+
+// NewMonitor crea un monitor; con normalizer nil usa los alias por defecto.
+func NewMonitor(rules []Rule, normalizer *models.StatusNormalizer) *Monitor {
+	if normalizer == nil {
+		normalizer = models.NewStatusNormalizer()
+	}
+	m := &Monitor{
+		normalizer: normalizer,
+		rules:      make(map[models.OrderStatus]map[string]time.Duration),
+		retention:  defaultRetention,
+		now:        time.Now,
+		notified:   newNotifiedStore(),
+		breaches:   make(map[string]*models.SLABreach),
+	}
+	for _, r := range rules {
+		m.setRule(r)
+	}
+	return m
+}
+
+// LoadMonitor monitor con las reglas de un archivo JSON (reemplazan las default).
+func LoadMonitor(path string, normalizer *models.StatusNormalizer) (*Monitor, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading SLA rules: %w", err)
+	}
+
+	var file rulesFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid SLA rules JSON: %w", err)
+	}
+
+	rules := make([]Rule, 0, len(file.Rules))
+	for _, r := range file.Rules {
+		limit, err := time.ParseDuration(r.Max)
+		if err != nil || limit <= 0 {
+			return nil, fmt.Errorf("invalid SLA max %q for status %s", r.Max, r.Status)
+		}
+		rules = append(rules, Rule{
+			Status:          models.NormalizeStatus(r.Status),
+			ShippingCompany: r.ShippingCompany,
+			Max:             limit,
+		})
+	}
+
+	m := NewMonitor(rules, normalizer)
+	if file.Retention != "" {
+		retention, err := time.ParseDuration(file.Retention)
+		if err != nil || retention <= 0 {
+			return nil, fmt.Errorf("invalid SLA retention %q", file.Retention)
+		}
+		m.retention = retention
+	}
+	return m, nil
+}
+
+func (m *Monitor) setRule(r Rule) {
+	if m.rules[r.Status] == nil {
+		m.rules[r.Status] = make(map[string]time.Duration)
+	}
+	m.rules[r.Status][carrierKey(r.ShippingCompany)] = r.Max
+}
+
+func carrierKey(company string) string {
+	return strings.ToUpper(strings.TrimSpace(company))
+}
+
+// thresholdFor tiempo máximo para el status y la transportadora; 0 si no hay regla.
+func (m *Monitor) thresholdFor(status models.OrderStatus, company string) time.Duration {
+	byCarrier := m.rules[status]
+	if limit, ok := byCarrier[carrierKey(company)]; ok {
+		return limit
+	}
+	return byCarrier[""]
+}
+
+func breachKey(country string, orderID int64) string {
+	return fmt.Sprintf("%s:%d", country, orderID)
+}
+
+// Evaluate revisa la orden (con el history ya normalizado) y retorna el
+// incumplimiento vigente, o nil si está dentro del SLA. Si la orden dejó el
+// status o ya no incumple, sale de la lista. scope es el destino consultado
+// (su Country es el dropi_country_suffix); Notified del resultado indica si
+// ese destino ya recibió el evento.
+func (m *Monitor) Evaluate(order *models.DropiOrder, scope state.Scope) *models.SLABreach {
+	country := scope.Country
+	key := breachKey(country, order.ID)
+	notifiedKey := state.SnapshotKey(scope, order.ID)
+	status := m.normalizer.Normalize(order.Status, country)
+
+	since, ok := m.enteredStatus(order, status, country)
+	limit := m.thresholdFor(status, order.ShippingCompany)
+	now := m.now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !ok || limit <= 0 || status.IsTerminal() || now.Sub(since) < limit {
+		delete(m.breaches, key)
+		m.notified.clear(notifiedKey)
+		return nil
+	}
+
+	breach, exists := m.breaches[key]
+	if !exists || breach.Status != status || !breach.Since.Equal(since) {
+		breach = &models.SLABreach{
+			OrderID:    order.ID,
+			Country:    country,
+			Status:     status,
+			Since:      since,
+			DetectedAt: now,
+		}
+		m.breaches[key] = breach
+	}
+	breach.ShopID = order.ShopID
+	breach.ShippingCompany = order.ShippingCompany
+	breach.ShippingGuide = order.ShippingGuide
+	breach.Threshold = models.Duration(limit)
+	breach.InStatus = models.Duration(now.Sub(since))
+	breach.LastSeenAt = now
+
+	out := *breach
+	out.Notified = m.notified.seen(notifiedKey, since, now)
+	return &out
+}
+
+// enteredStatus momento en que la orden entró al status actual: el primer
+// item del tramo final del history con ese status.
+func (m *Monitor) enteredStatus(order *models.DropiOrder, status models.OrderStatus, country string) (time.Time, bool) {
+	var since time.Time
+	found := false
+	for i := len(order.History) - 1; i >= 0; i-- {
+		h := order.History[i]
+		if m.normalizer.Normalize(h.Status, country) != status {
+			break
+		}
+		if t, err := h.CreatedTime(); err == nil {
+			since, found = t, true
+		}
+	}
+	return since, found
+}
+
+// MarkNotified registra que el evento del incumplimiento ya se encoló para el
+// destino de scope. En el listado Notified queda en true con el primer destino.
+func (m *Monitor) MarkNotified(scope state.Scope, b *models.SLABreach) {
+	m.notified.mark(state.SnapshotKey(scope, b.OrderID), b.Since, m.now())
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if cur, ok := m.breaches[breachKey(b.Country, b.OrderID)]; ok && cur.Since.Equal(b.Since) {
+		cur.Notified = true
+	}
+}
+
+// Filter criterios opcionales para listar incumplimientos.
+type Filter struct {
+	Country         string
+	Status          models.OrderStatus
+	ShippingCompany string
+}
+
+// Breaches incumplimientos vigentes, los más antiguos primero. Los que no se
+// vuelven a ver dentro de la retención se descartan.
+func (m *Monitor) Breaches(f Filter) []models.SLABreach {
+	now := m.now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	out := make([]models.SLABreach, 0, len(m.breaches))
+	for key, b := range m.breaches {
+		if now.Sub(b.LastSeenAt) > m.retention {
+			delete(m.breaches, key)
+			continue
+		}
+		if f.Country != "" && !strings.EqualFold(f.Country, b.Country) {
+			continue
+		}
+		if f.Status != "" && f.Status != b.Status {
+			continue
+		}
+		if f.ShippingCompany != "" && carrierKey(f.ShippingCompany) != carrierKey(b.ShippingCompany) {
+			continue
+		}
+		out = append(out, *b)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Since.Before(out[j].Since)
+	})
+	return out
+}
@@ -0,0 +1,89 @@
+package sla
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/juancollazo-ch/dropi-order-status-service/internal/models"
+	"github.com/juancollazo-ch/dropi-order-status-service/internal/state"
+)
+
+var testNow = time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+
+// breachedOrder orden en GUIA_GENERADA desde hace 80h (la regla default es 72h).
+func breachedOrder() *models.DropiOrder {
+	return &models.DropiOrder{
+		ID:     42,
+		Status: "GUIA_GENERADA",
+		History: []models.HistoryItem{
+			{ID: 1, Status: "PENDIENTE", CreatedAt: testNow.Add(-90 * time.Hour).Format(time.RFC3339)},
+			{ID: 2, Status: "GUIA_GENERADA", CreatedAt: testNow.Add(-80 * time.Hour).Format(time.RFC3339)},
+		},
+	}
+}
+
+func newTestMonitor(t *testing.T, path string) *Monitor {
+	t.Helper()
+	m, err := NewMonitor(DefaultRules(), nil).WithStateFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.now = func() time.Time { return testNow }
+	return m
+}
+
+func TestNotifiedPerDestination(t *testing.T) {
+	m := newTestMonitor(t, "")
+	a := state.Scope{TenantID: "t1", Country: "co", Destination: "client/a"}
+	b := state.Scope{TenantID: "t1", Country: "co", Destination: "client/b"}
+
+	breach := m.Evaluate(breachedOrder(), a)
+	if breach == nil || breach.Notified {
+		t.Fatalf("expected a new breach, got %+v", breach)
+	}
+	m.MarkNotified(a, breach)
+
+	if got := m.Evaluate(breachedOrder(), a); got == nil || !got.Notified {
+		t.Fatal("destination a should be marked as notified")
+	}
+	if got := m.Evaluate(breachedOrder(), b); got == nil || got.Notified {
+		t.Fatal("destination b must still receive the event")
+	}
+	if list := m.Breaches(Filter{}); len(list) != 1 {
+		t.Fatalf("expected one listed breach per order, got %d", len(list))
+	}
+}
+
+func TestNotifiedSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sla.json")
+	scope := state.Scope{Country: "co", Destination: "client/a"}
+
+	m := newTestMonitor(t, path)
+	m.MarkNotified(scope, m.Evaluate(breachedOrder(), scope))
+	if err := m.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	restarted := newTestMonitor(t, path)
+	if got := restarted.Evaluate(breachedOrder(), scope); got == nil || !got.Notified {
+		t.Fatal("notified state should be loaded from the state file")
+	}
+}
+
+func TestNotifiedClearedWhenBackInSLA(t *testing.T) {
+	m := newTestMonitor(t, "")
+	scope := state.Scope{Country: "co", Destination: "client/a"}
+	m.MarkNotified(scope, m.Evaluate(breachedOrder(), scope))
+
+	// La orden avanza y luego vuelve a GUIA_GENERADA: es otro incumplimiento
+	order := breachedOrder()
+	order.Status = "EN_TRANSITO"
+	order.History = append(order.History, models.HistoryItem{ID: 3, Status: "EN_TRANSITO", CreatedAt: testNow.Add(-1 * time.Hour).Format(time.RFC3339)})
+	if got := m.Evaluate(order, scope); got != nil {
+		t.Fatalf("expected no breach, got %+v", got)
+	}
+	if got := m.Evaluate(breachedOrder(), scope); got == nil || got.Notified {
+		t.Fatal("breach must be notified again after leaving the status")
+	}
+}
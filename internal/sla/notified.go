@@ -0,0 +1,169 @@
+package sla
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// notifiedEntry incumplimiento ya notificado a un destino: Since identifica el
+// tramo en el status (si la orden vuelve a entrar es otro incumplimiento).
+type notifiedEntry struct {
+	Since time.Time `json:"since"`
+	At    time.Time `json:"at"`
+}
+
+// notifiedStore qué incumplimientos ya se notificaron a cada destino. Con path
+// vacío queda solo en memoria; con archivo, Flush lo reescribe de forma atómica.
+type notifiedStore struct {
+	mu      sync.Mutex
+	entries map[string]notifiedEntry
+	path    string
+	dirty   bool
+	fmu     sync.Mutex
+}
+
+func newNotifiedStore() *notifiedStore {
+	return &notifiedStore{entries: make(map[string]notifiedEntry)}
+}
+
+// loadNotifiedStore carga el archivo si existe.
+func loadNotifiedStore(path string) (*notifiedStore, error) {
+	s := newNotifiedStore()
+	s.path = path
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading SLA state: %w", err)
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &s.entries); err != nil {
+			return nil, fmt.Errorf("invalid SLA state JSON: %w", err)
+		}
+	}
+	return s, nil
+}
+
+// seen indica si el incumplimiento ya se notificó y renueva su vigencia; el
+// archivo solo se reescribe si la marca tiene más de una hora.
+func (s *notifiedStore) seen(key string, since, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[key]
+	if !ok || !e.Since.Equal(since) {
+		return false
+	}
+	if now.Sub(e.At) > time.Hour {
+		e.At = now
+		s.entries[key] = e
+		s.dirty = true
+	}
+	return true
+}
+
+func (s *notifiedStore) mark(key string, since, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[key] = notifiedEntry{Since: since, At: now}
+	s.dirty = true
+}
+
+func (s *notifiedStore) clear(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.entries[key]; ok {
+		delete(s.entries, key)
+		s.dirty = true
+	}
+}
+
+// prune descarta las notificaciones anteriores a cutoff (órdenes que no se
+// volvieron a ver dentro de la retención).
+func (s *notifiedStore) prune(cutoff time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, e := range s.entries {
+		if e.At.Before(cutoff) {
+			delete(s.entries, key)
+			s.dirty = true
+		}
+	}
+}
+
+func (s *notifiedStore) flush() error {
+	if s.path == "" {
+		return nil
+	}
+
+	s.fmu.Lock()
+	defer s.fmu.Unlock()
+
+	s.mu.Lock()
+	if !s.dirty {
+		s.mu.Unlock()
+		return nil
+	}
+	data, err := json.Marshal(s.entries)
+	s.dirty = false
+	s.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("error encoding SLA state: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return s.flushFailed(fmt.Errorf("error writing SLA state: %w", err))
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return s.flushFailed(fmt.Errorf("error writing SLA state: %w", err))
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return s.flushFailed(fmt.Errorf("error writing SLA state: %w", err))
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		os.Remove(tmp.Name())
+		return s.flushFailed(fmt.Errorf("error writing SLA state: %w", err))
+	}
+	return nil
+}
+
+// flushFailed deja los cambios pendientes para el próximo flush.
+func (s *notifiedStore) flushFailed(err error) error {
+	s.mu.Lock()
+	s.dirty = true
+	s.mu.Unlock()
+	return err
+}
+
+// WithStateFile persiste en path qué incumplimientos ya se notificaron a cada
+// destino, así un reinicio no los vuelve a enviar; con path vacío quedan solo
+// en memoria.
+func (m *Monitor) WithStateFile(path string) (*Monitor, error) {
+	if path == "" {
+		slog.Warn("SLA_STATE_PATH no definido, notificaciones de SLA solo en memoria")
+		return m, nil
+	}
+	store, err := loadNotifiedStore(path)
+	if err != nil {
+		return nil, err
+	}
+	m.notified = store
+	return m, nil
+}
+
+// Flush persiste las notificaciones pendientes y descarta las vencidas.
+func (m *Monitor) Flush() error {
+	m.notified.prune(m.now().Add(-m.retention))
+	return m.notified.flush()
+}
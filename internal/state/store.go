@@ -0,0 +1,174 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/juancollazo-ch/dropi-order-status-service/internal/models"
+)
+
+// Store guarda el último snapshot conocido de cada orden.
+type Store interface {
+	Get(key string) (models.OrderSnapshot, bool, error)
+	Put(key string, snapshot models.OrderSnapshot) error
+	// Flush persiste los cambios pendientes.
+	Flush() error
+	// Persistent indica si los snapshots sobreviven a un reinicio.
+	Persistent() bool
+}
+
+// Scope a quién pertenece un snapshot: los ids solo son únicos dentro de un
+// país, y cada tenant y destino lleva su propia vista de la orden (un
+// destino que no recibió un cambio lo sigue viendo pendiente).
+type Scope struct {
+	TenantID    string
+	Country     string
+	Destination string // webhook_suffix
+}
+
+// SnapshotKey clave del snapshot de una orden dentro de scope.
+func SnapshotKey(scope Scope, orderID int64) string {
+	return fmt.Sprintf("%s:%s:%s:%d", scope.Country, scope.TenantID, scope.Destination, orderID)
+}
+
+// SeededKey marca que el scope ya tuvo una ejecución completa: sus órdenes
+// quedaron guardadas y a partir de ahí una orden sin snapshot es nueva. No
+// choca con las claves de órdenes porque no termina en un id.
+func SeededKey(scope Scope) string {
+	return fmt.Sprintf("%s:%s:%s:seeded", scope.Country, scope.TenantID, scope.Destination)
+}
+
+// LegacySnapshotKey clave usada antes de separar por tenant y destino
+// ("país:id"); solo se lee, para no ver como nuevas las órdenes ya guardadas.
+func LegacySnapshotKey(country string, orderID int64) string {
+	return fmt.Sprintf("%s:%d", country, orderID)
+}
+
+// NewStoreFromEnv usa un archivo si STATE_STORE_PATH está definido y memoria
+// en caso contrario (los snapshots se pierden al reiniciar).
+func NewStoreFromEnv() (Store, error) {
+	path := os.Getenv("STATE_STORE_PATH")
+	if path == "" {
+		slog.Warn("STATE_STORE_PATH no definido, snapshots de órdenes solo en memoria")
+		return NewMemoryStore(), nil
+	}
+	return NewFileStore(path)
+}
+
+// MemoryStore snapshots en memoria.
+type MemoryStore struct {
+	mu        sync.RWMutex
+	snapshots map[string]models.OrderSnapshot
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{snapshots: make(map[string]models.OrderSnapshot)}
+}
+
+func (m *MemoryStore) Get(key string) (models.OrderSnapshot, bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	s, ok := m.snapshots[key]
+	return s, ok, nil
+}
+
+func (m *MemoryStore) Put(key string, snapshot models.OrderSnapshot) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.snapshots[key] = snapshot
+	return nil
+}
+
+func (m *MemoryStore) Flush() error { return nil }
+
+func (m *MemoryStore) Persistent() bool { return false }
+
+// FileStore snapshots en memoria respaldados por un archivo JSON. Put solo
+// marca cambios; Flush reescribe el archivo de forma atómica.
+type FileStore struct {
+	MemoryStore
+	path  string
+	dirty bool
+	fmu   sync.Mutex
+}
+
+// NewFileStore carga el archivo si existe.
+func NewFileStore(path string) (*FileStore, error) {
+	f := &FileStore{
+		MemoryStore: MemoryStore{snapshots: make(map[string]models.OrderSnapshot)},
+		path:        path,
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading state store: %w", err)
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &f.snapshots); err != nil {
+			return nil, fmt.Errorf("invalid state store JSON: %w", err)
+		}
+	}
+	return f, nil
+}
+
+func (f *FileStore) Put(key string, snapshot models.OrderSnapshot) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.snapshots[key] = snapshot
+	f.dirty = true
+	return nil
+}
+
+func (f *FileStore) Persistent() bool { return true }
+
+func (f *FileStore) Flush() error {
+	f.fmu.Lock()
+	defer f.fmu.Unlock()
+
+	f.mu.Lock()
+	if !f.dirty {
+		f.mu.Unlock()
+		return nil
+	}
+	data, err := json.Marshal(f.snapshots)
+	f.dirty = false
+	f.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("error encoding state store: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".*.tmp")
+	if err != nil {
+		return f.flushFailed(fmt.Errorf("error writing state store: %w", err))
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return f.flushFailed(fmt.Errorf("error writing state store: %w", err))
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return f.flushFailed(fmt.Errorf("error writing state store: %w", err))
+	}
+	if err := os.Rename(tmp.Name(), f.path); err != nil {
+		os.Remove(tmp.Name())
+		return f.flushFailed(fmt.Errorf("error writing state store: %w", err))
+	}
+	return nil
+}
+
+// flushFailed deja los cambios pendientes para el próximo Flush.
+func (f *FileStore) flushFailed(err error) error {
+	f.mu.Lock()
+	f.dirty = true
+	f.mu.Unlock()
+	return err
+}
@@ -0,0 +1,167 @@
+package tenant
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// KeyProvider cifra y descifra las claves de datos (DEK) con una clave maestra
+// (KEK). Es la misma interfaz que ofrecen los KMS (Cloud KMS, AWS KMS, Vault
+// transit): la clave maestra nunca sale del proveedor.
+type KeyProvider interface {
+	// KeyID identifica la clave maestra actual; se guarda junto al dato cifrado
+	KeyID() string
+	WrapKey(dek []byte) ([]byte, error)
+	UnwrapKey(keyID string, wrapped []byte) ([]byte, error)
+}
+
+// Envelope dato cifrado con una DEK propia, que a su vez va cifrada con la KEK.
+type Envelope struct {
+	KeyID      string `json:"key_id"`
+	WrappedKey []byte `json:"wrapped_key"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// Seal cifra plaintext con una DEK nueva (AES-256-GCM). aad liga el dato
+// cifrado a su dueño (el id del tenant): no se puede copiar a otro tenant.
+func Seal(p KeyProvider, plaintext, aad []byte) (Envelope, error) {
+	dek := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, dek); err != nil {
+		return Envelope{}, fmt.Errorf("error generating data key: %w", err)
+	}
+
+	nonce, ciphertext, err := gcmSeal(dek, plaintext, aad)
+	if err != nil {
+		return Envelope{}, err
+	}
+
+	wrapped, err := p.WrapKey(dek)
+	if err != nil {
+		return Envelope{}, fmt.Errorf("error wrapping data key: %w", err)
+	}
+
+	return Envelope{
+		KeyID:      p.KeyID(),
+		WrappedKey: wrapped,
+		Nonce:      nonce,
+		Ciphertext: ciphertext,
+	}, nil
+}
+
+// Open descifra el envelope.
+func Open(p KeyProvider, env Envelope, aad []byte) ([]byte, error) {
+	dek, err := p.UnwrapKey(env.KeyID, env.WrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("error unwrapping data key: %w", err)
+	}
+	return gcmOpen(dek, env.Nonce, env.Ciphertext, aad)
+}
+
+func gcmSeal(key, plaintext, aad []byte) (nonce, ciphertext []byte, err error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce = make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, nil, err
+	}
+	return nonce, gcm.Seal(nil, nonce, plaintext, aad), nil
+}
+
+func gcmOpen(key, nonce, ciphertext, aad []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != gcm.NonceSize() {
+		return nil, errors.New("invalid nonce size")
+	}
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, aad)
+	if err != nil {
+		return nil, errors.New("decryption failed")
+	}
+	return plaintext, nil
+}
+
+// LocalKeyProvider KEK leída de un archivo local (32 bytes crudos, en hex o
+// en base64). Sirve para desarrollo o despliegues sin KMS.
+type LocalKeyProvider struct {
+	id  string
+	kek []byte
+}
+
+// NewLocalKeyProvider carga la clave maestra del archivo.
+func NewLocalKeyProvider(path string) (*LocalKeyProvider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading key file: %w", err)
+	}
+
+	kek, err := decodeKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key file %s: %w", path, err)
+	}
+
+	// El id se deriva de la clave para detectar un archivo equivocado
+	sum := sha256.Sum256(kek)
+	return &LocalKeyProvider{
+		id:  "local:" + hex.EncodeToString(sum[:4]),
+		kek: kek,
+	}, nil
+}
+
+func decodeKey(data []byte) ([]byte, error) {
+	if len(data) == 32 {
+		return data, nil
+	}
+	s := strings.TrimSpace(string(data))
+	if b, err := hex.DecodeString(s); err == nil && len(b) == 32 {
+		return b, nil
+	}
+	if b, err := base64.StdEncoding.DecodeString(s); err == nil && len(b) == 32 {
+		return b, nil
+	}
+	return nil, errors.New("expected a 32 byte key (raw, hex or base64)")
+}
+
+func (p *LocalKeyProvider) KeyID() string {
+	return p.id
+}
+
+func (p *LocalKeyProvider) WrapKey(dek []byte) ([]byte, error) {
+	nonce, ciphertext, err := gcmSeal(p.kek, dek, []byte(p.id))
+	if err != nil {
+		return nil, err
+	}
+	return append(nonce, ciphertext...), nil
+}
+
+func (p *LocalKeyProvider) UnwrapKey(keyID string, wrapped []byte) ([]byte, error) {
+	if keyID != p.id {
+		return nil, fmt.Errorf("unknown key id %q", keyID)
+	}
+	const nonceSize = 12
+	if len(wrapped) < nonceSize {
+		return nil, errors.New("wrapped key too short")
+	}
+	return gcmOpen(p.kek, wrapped[:nonceSize], wrapped[nonceSize:], []byte(p.id))
+}
@@ -0,0 +1,204 @@
+package tenant
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testKeyProvider KEK local de 32 bytes escrita en hex en un archivo temporal.
+func testKeyProvider(t *testing.T, seed byte) *LocalKeyProvider {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "kek")
+	if err := os.WriteFile(path, []byte(hex.EncodeToString(bytes.Repeat([]byte{seed}, 32))), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	p, err := NewLocalKeyProvider(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestEnvelopeRoundTrip(t *testing.T) {
+	p := testKeyProvider(t, 1)
+	secret := []byte("integration-key-123")
+
+	env, err := Seal(p, secret, []byte("acme"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if env.KeyID != p.KeyID() {
+		t.Fatalf("KeyID = %q, want %q", env.KeyID, p.KeyID())
+	}
+	if bytes.Contains(env.Ciphertext, secret) {
+		t.Fatal("ciphertext must not contain the plaintext")
+	}
+
+	// El envelope se guarda como JSON en el registry
+	data, err := json.Marshal(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stored Envelope
+	if err := json.Unmarshal(data, &stored); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Open(p, stored, []byte("acme"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, secret) {
+		t.Fatalf("Open = %q, want %q", got, secret)
+	}
+}
+
+func TestEnvelopeUsesFreshDataKey(t *testing.T) {
+	p := testKeyProvider(t, 1)
+	a, err := Seal(p, []byte("same"), []byte("acme"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := Seal(p, []byte("same"), []byte("acme"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(a.WrappedKey, b.WrappedKey) || bytes.Equal(a.Ciphertext, b.Ciphertext) {
+		t.Fatal("each Seal must use a new data key and nonce")
+	}
+}
+
+func TestEnvelopeOpenFailures(t *testing.T) {
+	p := testKeyProvider(t, 1)
+	env, err := Seal(p, []byte("secret"), []byte("acme"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tampered := env
+	tampered.Ciphertext = append([]byte(nil), env.Ciphertext...)
+	tampered.Ciphertext[0] ^= 0xff
+
+	badNonce := env
+	badNonce.Nonce = env.Nonce[:4]
+
+	tests := []struct {
+		name string
+		p    KeyProvider
+		env  Envelope
+		aad  string
+	}{
+		// aad liga la key al tenant: copiarla a otro tenant no sirve
+		{"other tenant", p, env, "other"},
+		{"other master key", testKeyProvider(t, 2), env, "acme"},
+		{"tampered ciphertext", p, tampered, "acme"},
+		{"bad nonce", p, badNonce, "acme"},
+	}
+	for _, tt := range tests {
+		if _, err := Open(tt.p, tt.env, []byte(tt.aad)); err == nil {
+			t.Errorf("%s: Open should fail", tt.name)
+		}
+	}
+}
+
+func TestDecodeKey(t *testing.T) {
+	raw := bytes.Repeat([]byte{7}, 32)
+	tests := []struct {
+		name string
+		data []byte
+		ok   bool
+	}{
+		{"raw", raw, true},
+		{"hex", []byte(hex.EncodeToString(raw) + "\n"), true},
+		{"base64", []byte("BwcHBwcHBwcHBwcHBwcHBwcHBwcHBwcHBwcHBwcHBwc="), true},
+		{"short", []byte("abc"), false},
+	}
+	for _, tt := range tests {
+		key, err := decodeKey(tt.data)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: err = %v", tt.name, err)
+			continue
+		}
+		if tt.ok && !bytes.Equal(key, raw) {
+			t.Errorf("%s: decoded key mismatch", tt.name)
+		}
+	}
+}
+
+func TestRegistryIntegrationKey(t *testing.T) {
+	p := testKeyProvider(t, 1)
+	path := filepath.Join(t.TempDir(), "tenants.json")
+	r, err := NewRegistry(path, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	name, key := "Acme", "dropi-key"
+	if _, err := r.Create(Input{ID: "acme", Name: &name, CountrySuffixes: []string{"co"}, IntegrationKey: &key}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Otra instancia lee el archivo y descifra con la misma KEK
+	reloaded, err := NewRegistry(path, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := reloaded.IntegrationKey("acme")
+	if err != nil || got != key {
+		t.Fatalf("IntegrationKey = %q, %v", got, err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte(key)) {
+		t.Fatal("registry file must not contain the plaintext key")
+	}
+}
+
+func TestRegistryValidatesSchedule(t *testing.T) {
+	r, err := NewRegistry("", testKeyProvider(t, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.WithScheduleValidator(func(expr string) error {
+		if expr != "@every 1h" {
+			return errors.New("unsupported")
+		}
+		return nil
+	})
+
+	name, schedule, zone := "Acme", "@every 1h", "America/Bogota"
+	if _, err := r.Create(Input{ID: "acme", Name: &name, CountrySuffixes: []string{"co"}, Schedule: &schedule, TimeZone: &zone}); err != nil {
+		t.Fatal(err)
+	}
+
+	bad, badZone := "every hour", "Mars/Olympus"
+	tests := []struct {
+		name string
+		in   Input
+	}{
+		{"schedule", Input{Schedule: &bad}},
+		{"time zone", Input{TimeZone: &badZone}},
+	}
+	for _, tt := range tests {
+		if _, err := r.Update("acme", tt.in); err == nil {
+			t.Errorf("%s: expected a validation error", tt.name)
+		}
+	}
+
+	// Lo inválido nunca queda guardado
+	got, err := r.Get("acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Schedule != schedule || got.TimeZone != zone {
+		t.Fatalf("invalid schedule stored: %q %q", got.Schedule, got.TimeZone)
+	}
+}
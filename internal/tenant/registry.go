@@ -0,0 +1,412 @@
+package tenant
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/juancollazo-ch/dropi-order-status-service/internal/models"
+	"github.com/juancollazo-ch/dropi-order-status-service/internal/validator"
+)
+
+var (
+	ErrNotFound       = errors.New("tenant not found")
+	ErrAlreadyExists  = errors.New("tenant already exists")
+	ErrNoKey          = errors.New("tenant has no integration key")
+	ErrNoDestinations = errors.New("tenant has no destinations")
+	ErrKeyUnavailable = errors.New("tenant integration key could not be decrypted")
+	ErrStorage        = errors.New("tenant registry storage error")
+)
+
+var idRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+// Destination webhook de un tenant. Filter son los cambios que el receptor
+// quiere recibir; se aplica a todo request hacia este destino.
+type Destination struct {
+	WebhookSuffix  string                `json:"webhook_suffix"`
+	WebhookFormat  models.WebhookFormat  `json:"webhook_format,omitempty"`
+	PayloadVersion models.PayloadVersion `json:"payload_version,omitempty"`
+	Filter         *models.WebhookFilter `json:"filter,omitempty"`
+}
+
+// Tenant vendedor registrado. La integration key solo se guarda cifrada.
+type Tenant struct {
+	ID              string        `json:"id"`
+	Name            string        `json:"name"`
+	CountrySuffixes []string      `json:"country_suffixes"`
+	Destinations    []Destination `json:"destinations"`
+	Schedule        string        `json:"schedule,omitempty"` // expresión cron para el scheduler
+	TimeZone        string        `json:"time_zone,omitempty"`
+	IntegrationKey  *Envelope     `json:"integration_key,omitempty"`
+	CreatedAt       time.Time     `json:"created_at"`
+	UpdatedAt       time.Time     `json:"updated_at"`
+}
+
+// View datos del tenant sin la key cifrada, para las respuestas de la API.
+type View struct {
+	ID              string        `json:"id"`
+	Name            string        `json:"name"`
+	CountrySuffixes []string      `json:"country_suffixes"`
+	Destinations    []Destination `json:"destinations"`
+	Schedule        string        `json:"schedule,omitempty"`
+	TimeZone        string        `json:"time_zone,omitempty"`
+	HasKey          bool          `json:"has_integration_key"`
+	KeyID           string        `json:"key_id,omitempty"`
+	CreatedAt       time.Time     `json:"created_at"`
+	UpdatedAt       time.Time     `json:"updated_at"`
+}
+
+func (t Tenant) View() View {
+	v := View{
+		ID:              t.ID,
+		Name:            t.Name,
+		CountrySuffixes: t.CountrySuffixes,
+		Destinations:    t.Destinations,
+		Schedule:        t.Schedule,
+		TimeZone:        t.TimeZone,
+		HasKey:          t.IntegrationKey != nil,
+		CreatedAt:       t.CreatedAt,
+		UpdatedAt:       t.UpdatedAt,
+	}
+	if t.IntegrationKey != nil {
+		v.KeyID = t.IntegrationKey.KeyID
+	}
+	return v
+}
+
+// Input datos para crear o actualizar un tenant. En una actualización los
+// campos nil no se modifican; IntegrationKey reemplaza la key (rotación).
+type Input struct {
+	ID              string        `json:"id"`
+	Name            *string       `json:"name,omitempty"`
+	CountrySuffixes []string      `json:"country_suffixes,omitempty"`
+	Destinations    []Destination `json:"destinations,omitempty"`
+	Schedule        *string       `json:"schedule,omitempty"`
+	TimeZone        *string       `json:"time_zone,omitempty"`
+	IntegrationKey  *string       `json:"integration_key,omitempty"`
+}
+
+// Registry tenants guardados en un archivo JSON (o solo en memoria si path
+// está vacío). Las keys se cifran con envelope encryption.
+type Registry struct {
+	keys KeyProvider
+	path string
+
+	mu       sync.RWMutex
+	tenants  map[string]Tenant
+	onChange []func([]Tenant)
+
+	// validateSchedule valida la expresión cron; la define quien ejecuta los
+	// schedules (el scheduler importa este paquete)
+	validateSchedule func(string) error
+}
+
+// NewRegistry carga el archivo si existe.
+func NewRegistry(path string, keys KeyProvider) (*Registry, error) {
+	r := &Registry{
+		keys:    keys,
+		path:    path,
+		tenants: make(map[string]Tenant),
+	}
+	if path == "" {
+		return r, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading tenant registry: %w", err)
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &r.tenants); err != nil {
+			return nil, fmt.Errorf("invalid tenant registry JSON: %w", err)
+		}
+	}
+	return r, nil
+}
+
+// WithScheduleValidator define cómo validar el schedule de un tenant; un
+// schedule que no pasa la validación nunca se guarda.
+func (r *Registry) WithScheduleValidator(fn func(string) error) *Registry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.validateSchedule = fn
+	return r
+}
+
+// OnChange registra fn para que reciba la lista de tenants después de cada
+// Create, Update o Delete exitoso (ej. para reprogramar el scheduler). fn se
+// llama sin el lock del registry.
+func (r *Registry) OnChange(fn func([]Tenant)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.onChange = append(r.onChange, fn)
+}
+
+// notifyChange avisa a los listeners si la operación terminó sin error; se
+// difiere antes de tomar el lock para que corra después de liberarlo.
+func (r *Registry) notifyChange(err *error) {
+	if *err != nil {
+		return
+	}
+	r.mu.RLock()
+	listeners := r.onChange
+	r.mu.RUnlock()
+	if len(listeners) == 0 {
+		return
+	}
+	tenants := r.List()
+	for _, fn := range listeners {
+		fn(tenants)
+	}
+}
+
+// List tenants ordenados por id.
+func (r *Registry) List() []Tenant {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	out := make([]Tenant, 0, len(r.tenants))
+	for _, t := range r.tenants {
+		out = append(out, t)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+func (r *Registry) Get(id string) (Tenant, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	t, ok := r.tenants[id]
+	if !ok {
+		return Tenant{}, ErrNotFound
+	}
+	return t, nil
+}
+
+// Create registra un tenant nuevo.
+func (r *Registry) Create(in Input) (_ Tenant, err error) {
+	if !idRegex.MatchString(in.ID) {
+		return Tenant{}, fmt.Errorf("id must match %s", idRegex.String())
+	}
+
+	defer r.notifyChange(&err)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.tenants[in.ID]; ok {
+		return Tenant{}, ErrAlreadyExists
+	}
+
+	now := time.Now().UTC()
+	t := Tenant{ID: in.ID, CreatedAt: now}
+	if err := r.apply(&t, in); err != nil {
+		return Tenant{}, err
+	}
+	t.UpdatedAt = now
+
+	r.tenants[t.ID] = t
+	if err := r.saveLocked(); err != nil {
+		delete(r.tenants, t.ID)
+		return Tenant{}, err
+	}
+	return t, nil
+}
+
+// Update modifica un tenant existente.
+func (r *Registry) Update(id string, in Input) (_ Tenant, err error) {
+	defer r.notifyChange(&err)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	prev, ok := r.tenants[id]
+	if !ok {
+		return Tenant{}, ErrNotFound
+	}
+
+	t := prev
+	if err := r.apply(&t, in); err != nil {
+		return Tenant{}, err
+	}
+	t.UpdatedAt = time.Now().UTC()
+
+	r.tenants[id] = t
+	if err := r.saveLocked(); err != nil {
+		r.tenants[id] = prev
+		return Tenant{}, err
+	}
+	return t, nil
+}
+
+func (r *Registry) Delete(id string) (err error) {
+	defer r.notifyChange(&err)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	prev, ok := r.tenants[id]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(r.tenants, id)
+	if err := r.saveLocked(); err != nil {
+		r.tenants[id] = prev
+		return err
+	}
+	return nil
+}
+
+// IntegrationKey descifra la key del tenant. No guardarla ni loguearla.
+func (r *Registry) IntegrationKey(id string) (string, error) {
+	t, err := r.Get(id)
+	if err != nil {
+		return "", err
+	}
+	if t.IntegrationKey == nil {
+		return "", ErrNoKey
+	}
+	plaintext, err := Open(r.keys, *t.IntegrationKey, []byte(t.ID))
+	if err != nil {
+		return "", fmt.Errorf("%w: tenant %s: %v", ErrKeyUnavailable, t.ID, err)
+	}
+	return string(plaintext), nil
+}
+
+// apply copia los campos de in en t, cifrando la key si viene.
+func (r *Registry) apply(t *Tenant, in Input) error {
+	if in.Name != nil {
+		t.Name = *in.Name
+	}
+	v := validator.NewRequestValidator()
+	if in.CountrySuffixes != nil {
+		for _, suffix := range in.CountrySuffixes {
+			if err := v.ValidateCountrySuffix(suffix); err != nil {
+				return err
+			}
+		}
+		t.CountrySuffixes = in.CountrySuffixes
+	}
+	if in.Destinations != nil {
+		for _, d := range in.Destinations {
+			if err := v.ValidateWebhookSuffix(d.WebhookSuffix); err != nil {
+				return err
+			}
+			if !d.WebhookFormat.IsValid() {
+				return fmt.Errorf("invalid webhook_format %q", d.WebhookFormat)
+			}
+			if !d.PayloadVersion.IsValid() {
+				return fmt.Errorf("invalid payload_version %q", d.PayloadVersion)
+			}
+			if err := d.Filter.Validate(); err != nil {
+				return err
+			}
+		}
+		t.Destinations = in.Destinations
+	}
+	if in.Schedule != nil {
+		if *in.Schedule != "" && r.validateSchedule != nil {
+			if err := r.validateSchedule(*in.Schedule); err != nil {
+				return fmt.Errorf("invalid schedule %q: %w", *in.Schedule, err)
+			}
+		}
+		t.Schedule = *in.Schedule
+	}
+	if in.TimeZone != nil {
+		if *in.TimeZone != "" {
+			if _, err := time.LoadLocation(*in.TimeZone); err != nil {
+				return fmt.Errorf("invalid time_zone %q: %w", *in.TimeZone, err)
+			}
+		}
+		t.TimeZone = *in.TimeZone
+	}
+	if in.IntegrationKey != nil {
+		if *in.IntegrationKey == "" {
+			t.IntegrationKey = nil
+		} else {
+			env, err := Seal(r.keys, []byte(*in.IntegrationKey), []byte(t.ID))
+			if err != nil {
+				return err
+			}
+			t.IntegrationKey = &env
+		}
+	}
+
+	if t.Name == "" {
+		return errors.New("name is required")
+	}
+	if len(t.CountrySuffixes) == 0 {
+		return errors.New("at least one country suffix is required")
+	}
+	return nil
+}
+
+// saveLocked reescribe el archivo de forma atómica; requiere r.mu.
+func (r *Registry) saveLocked() error {
+	if r.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(r.tenants, "", "  ")
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrStorage, err)
+	}
+	if err := writeFileAtomic(r.path, data); err != nil {
+		return fmt.Errorf("%w: %v", ErrStorage, err)
+	}
+	return nil
+}
+
+// writeFileAtomic escribe en un temporal y renombra: un corte a mitad de la
+// escritura no deja el registry corrupto.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if err := tmp.Chmod(0o600); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+// NewRegistryFromEnv registry configurado con TENANT_REGISTRY_PATH y
+// TENANT_KEY_FILE; nil si no está configurado.
+func NewRegistryFromEnv() (*Registry, error) {
+	keyFile := os.Getenv("TENANT_KEY_FILE")
+	path := os.Getenv("TENANT_REGISTRY_PATH")
+	if keyFile == "" {
+		if path != "" {
+			return nil, errors.New("TENANT_REGISTRY_PATH requires TENANT_KEY_FILE")
+		}
+		return nil, nil
+	}
+
+	keys, err := NewLocalKeyProvider(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	return NewRegistry(path, keys)
+}
@@ -0,0 +1,156 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/juancollazo-ch/dropi-order-status-service/internal/models"
+	"go.uber.org/zap"
+)
+
+const cloudEventsBatchContentType = "application/cloudevents-batch+json"
+
+// BatchItemResult es el resultado por item que el receptor puede retornar al
+// recibir un batch: {"results":[{"order_id":123,"ok":false,"error":"..."}]}
+type BatchItemResult struct {
+	OrderID int64  `json:"order_id"`
+	OK      bool   `json:"ok"`
+	Error   string `json:"error,omitempty"`
+}
+
+type batchResponse struct {
+	Results []BatchItemResult `json:"results"`
+}
+
+// DestinationKey identifica el destino de la entrega; solo se agrupan en un
+// mismo batch entregas con la misma clave.
+func (d Delivery) DestinationKey() string {
+	return d.WebhookSuffix + "|" + string(d.Format.OrDefault()) + "|" + string(d.payloadVersion()) + "|" + string(d.Event.OrDefault())
+}
+
+// Batchable indica si el formato admite varias entregas en un solo POST.
+// CloudEvents en modo binario no define batches.
+func (d Delivery) Batchable() bool {
+	return d.Format.OrDefault() != models.WebhookFormatCloudEventsBinary
+}
+
+// SendBatch envía varias entregas del mismo destino en un solo POST con un
+// arreglo como body. Si el receptor responde con resultados por item, retorna
+// las entregas que reportó como fallidas para que se reintenten individualmente.
+// Un error indica que el batch completo falló.
+func (s *Sender) SendBatch(ctx context.Context, batch []Delivery) ([]Delivery, error) {
+	if len(batch) == 0 {
+		return nil, nil
+	}
+
+	resolved := make([]Delivery, len(batch))
+	for i, d := range batch {
+		resolved[i] = s.Resolve(d)
+	}
+
+	first := resolved[0]
+	for _, d := range resolved[1:] {
+		if d.DestinationKey() != first.DestinationKey() {
+			return nil, fmt.Errorf("batch contains deliveries for different destinations")
+		}
+	}
+	if !first.Batchable() {
+		return nil, fmt.Errorf("webhook format %s does not support batches", first.Format)
+	}
+
+	url, err := s.BuildWebhookURL(first.WebhookSuffix)
+	if err != nil {
+		return nil, err
+	}
+
+	body, headers, err := encodeBatch(resolved)
+	if err != nil {
+		return nil, err
+	}
+
+	zap.L().Info("sending webhook batch",
+		zap.String("url", url),
+		zap.Int("batch_size", len(batch)),
+		zap.String("format", string(first.Format.OrDefault())),
+	)
+
+	respBody, err := s.deliver(ctx, url, body, headers, zap.Int("batch_size", len(batch)))
+	if err != nil {
+		return nil, err
+	}
+
+	return failedItems(batch, respBody), nil
+}
+
+// encodeBatch serializa el batch: arreglo de payloads para JSON o arreglo de
+// eventos (application/cloudevents-batch+json) para CloudEvents estructurado.
+func encodeBatch(batch []Delivery) ([]byte, http.Header, error) {
+	headers := http.Header{}
+	headers.Set("X-Batch-Size", fmt.Sprintf("%d", len(batch)))
+	headers.Set(PayloadVersionHeader, string(batch[0].payloadVersion()))
+	headers.Set(EventHeader, string(batch[0].Event.OrDefault()))
+
+	if batch[0].Format.OrDefault() == models.WebhookFormatCloudEventsStructured {
+		events := make([]CloudEvent, 0, len(batch))
+		for _, d := range batch {
+			data, err := json.Marshal(d.payload())
+			if err != nil {
+				return nil, nil, fmt.Errorf("error marshaling webhook payload: %w", err)
+			}
+			events = append(events, newDeliveryEvent(d, data))
+		}
+
+		body, err := json.Marshal(events)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error marshaling cloudevents batch: %w", err)
+		}
+		headers.Set("Content-Type", cloudEventsBatchContentType)
+		return body, headers, nil
+	}
+
+	payloads := make([]interface{}, 0, len(batch))
+	for _, d := range batch {
+		payloads = append(payloads, d.payload())
+	}
+
+	body, err := json.Marshal(payloads)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error marshaling webhook batch: %w", err)
+	}
+	headers.Set("Content-Type", "application/json")
+	return body, headers, nil
+}
+
+// failedItems interpreta la respuesta del receptor. Si no trae resultados por
+// item, el 2xx aplica a todo el batch.
+func failedItems(batch []Delivery, respBody []byte) []Delivery {
+	if len(respBody) == 0 {
+		return nil
+	}
+
+	var resp batchResponse
+	if err := json.Unmarshal(respBody, &resp); err != nil || len(resp.Results) == 0 {
+		return nil
+	}
+
+	failed := make(map[int64]string, len(resp.Results))
+	for _, r := range resp.Results {
+		if !r.OK {
+			failed[r.OrderID] = r.Error
+		}
+	}
+
+	var out []Delivery
+	for _, d := range batch {
+		if reason, ok := failed[d.Order.ID]; ok {
+			zap.L().Warn("webhook batch item rejected",
+				zap.Int64("order_id", d.Order.ID),
+				zap.String("reason", reason),
+			)
+			out = append(out, d)
+		}
+	}
+	return out
+}
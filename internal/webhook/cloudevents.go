@@ -0,0 +1,159 @@
+package webhook
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/juancollazo-ch/dropi-order-status-service/internal/models"
+)
+
+const (
+	// CloudEventsSpecVersion versión de la especificación soportada
+	CloudEventsSpecVersion = "1.0"
+
+	// EventTypeOrderStatusChanged tipo de evento emitido cuando cambia el status
+	EventTypeOrderStatusChanged = "co.dropi.order.status.changed"
+	// EventTypeOrderCreated tipo de evento emitido cuando se ve una orden nueva
+	EventTypeOrderCreated = "co.dropi.order.created"
+	// EventTypeOrderSLABreached tipo de evento emitido cuando una orden incumple su SLA
+	EventTypeOrderSLABreached = "co.dropi.order.sla.breached"
+
+	cloudEventsContentType = "application/cloudevents+json"
+)
+
+// CloudEvent representa un evento CloudEvents 1.0 en modo estructurado.
+type CloudEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Subject         string          `json:"subject,omitempty"`
+	Time            string          `json:"time,omitempty"`
+	DataContentType string          `json:"datacontenttype,omitempty"`
+	Data            json.RawMessage `json:"data,omitempty"`
+}
+
+// NewOrderStatusEvent construye el evento CloudEvents para un cambio de status.
+// data es el payload ya serializado que viaja como "data" del evento.
+func NewOrderStatusEvent(order models.DropiOrder, countrySuffix string, data []byte) CloudEvent {
+	return CloudEvent{
+		SpecVersion:     CloudEventsSpecVersion,
+		ID:              transitionEventID(order),
+		Source:          eventSource(countrySuffix, order.ShopID),
+		Type:            EventTypeOrderStatusChanged,
+		Subject:         fmt.Sprintf("%d", order.ID),
+		Time:            eventTime(order),
+		DataContentType: "application/json",
+		Data:            data,
+	}
+}
+
+// NewOrderCreatedEvent construye el evento CloudEvents de una orden nueva. El
+// id depende solo de la orden: se emite una vez por orden.
+func NewOrderCreatedEvent(order models.DropiOrder, countrySuffix string, data []byte) CloudEvent {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("created:%d", order.ID)))
+
+	var at string
+	if t, err := order.CreatedTime(); err == nil {
+		at = t.Format(time.RFC3339Nano)
+	}
+
+	return CloudEvent{
+		SpecVersion:     CloudEventsSpecVersion,
+		ID:              hex.EncodeToString(sum[:16]),
+		Source:          eventSource(countrySuffix, order.ShopID),
+		Type:            EventTypeOrderCreated,
+		Subject:         fmt.Sprintf("%d", order.ID),
+		Time:            at,
+		DataContentType: "application/json",
+		Data:            data,
+	}
+}
+
+// NewSLABreachedEvent construye el evento CloudEvents de un incumplimiento de
+// SLA; el id se repite solo para el mismo status y la misma fecha de entrada.
+func NewSLABreachedEvent(order models.DropiOrder, countrySuffix string, data []byte) CloudEvent {
+	event := NewOrderStatusEvent(order, countrySuffix, data)
+	event.Type = EventTypeOrderSLABreached
+	event.Time = ""
+
+	if b := order.SLABreach; b != nil {
+		sum := sha256.Sum256([]byte(fmt.Sprintf("sla:%d:%s:%d", order.ID, b.Status, b.Since.Unix())))
+		event.ID = hex.EncodeToString(sum[:16])
+		event.Time = b.LastSeenAt.UTC().Format(time.RFC3339Nano)
+	}
+	return event
+}
+
+// newDeliveryEvent evento CloudEvents según el tipo de evento de la entrega.
+func newDeliveryEvent(d Delivery, data []byte) CloudEvent {
+	switch d.Event {
+	case models.EventOrderCreated:
+		return NewOrderCreatedEvent(d.Order, d.CountrySuffix, data)
+	case models.EventSLABreached:
+		return NewSLABreachedEvent(d.Order, d.CountrySuffix, data)
+	}
+	return NewOrderStatusEvent(d.Order, d.CountrySuffix, data)
+}
+
+// eventSource arma el "source" a partir del país y la tienda: /dropi/co/shops/123
+func eventSource(countrySuffix string, shopID int64) string {
+	if countrySuffix == "" {
+		countrySuffix = "unknown"
+	}
+	return fmt.Sprintf("/dropi/%s/shops/%d", countrySuffix, shopID)
+}
+
+// transitionEventID genera un id estable por transición: la misma orden en el
+// mismo estado del history siempre produce el mismo id, lo que permite a los
+// consumidores deduplicar reenvíos. Los cambios de campos (guía, novedad, ...)
+// entran en el id para que no se confundan con la transición anterior.
+func transitionEventID(order models.DropiOrder) string {
+	var historyID int64
+	if n := len(order.History); n > 0 {
+		historyID = order.History[n-1].ID
+	}
+
+	key := fmt.Sprintf("%d:%d:%s", order.ID, historyID, order.Status)
+	for _, c := range order.FieldChanges {
+		if c.Field != models.FieldStatus {
+			key += fmt.Sprintf(":%s=%s", c.Field, c.New)
+		}
+	}
+
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:16])
+}
+
+// eventTime usa la fecha del último item del history si se puede interpretar.
+func eventTime(order models.DropiOrder) string {
+	n := len(order.History)
+	if n == 0 {
+		return ""
+	}
+
+	t, err := order.History[n-1].CreatedTime()
+	if err != nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// applyBinaryHeaders agrega los atributos del evento como headers ce-* (modo binario).
+func (e CloudEvent) applyBinaryHeaders(h http.Header) {
+	h.Set("ce-specversion", e.SpecVersion)
+	h.Set("ce-id", e.ID)
+	h.Set("ce-source", e.Source)
+	h.Set("ce-type", e.Type)
+	if e.Subject != "" {
+		h.Set("ce-subject", e.Subject)
+	}
+	if e.Time != "" {
+		h.Set("ce-time", e.Time)
+	}
+	h.Set("Content-Type", e.DataContentType)
+}
@@ -0,0 +1,179 @@
+package webhook
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/juancollazo-ch/dropi-order-status-service/internal/models"
+)
+
+func testDelivery(format models.WebhookFormat, event models.WebhookEvent) Delivery {
+	d := Delivery{Format: format, Event: event, CountrySuffix: "co"}
+	d.Order.ID = 42
+	d.Order.ShopID = 7
+	d.Order.Status = "ENTREGADO"
+	d.Order.CreatedAt = "2024-01-10T08:00:00Z"
+	d.Order.History = []models.HistoryItem{
+		{ID: 1, Status: "PENDIENTE", CreatedAt: "2024-01-10T08:00:00Z"},
+		{ID: 2, Status: "ENTREGADO", CreatedAt: "2024-01-11T09:30:00Z"},
+	}
+	if event == models.EventSLABreached {
+		d.Order.SLABreach = &models.SLABreach{
+			OrderID:    42,
+			Status:     models.StatusGuiaGenerada,
+			Since:      time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC),
+			LastSeenAt: time.Date(2024, 1, 11, 12, 0, 0, 0, time.UTC),
+		}
+	}
+	return d
+}
+
+func TestEncodeCloudEventsBinary(t *testing.T) {
+	tests := []struct {
+		event    models.WebhookEvent
+		wantType string
+	}{
+		{models.EventStatusChanged, EventTypeOrderStatusChanged},
+		{models.EventOrderCreated, EventTypeOrderCreated},
+		{models.EventSLABreached, EventTypeOrderSLABreached},
+	}
+	for _, tt := range tests {
+		d := testDelivery(models.WebhookFormatCloudEventsBinary, tt.event)
+		body, headers, err := d.encode()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := map[string]string{
+			"ce-specversion": CloudEventsSpecVersion,
+			"ce-type":        tt.wantType,
+			"ce-source":      "/dropi/co/shops/7",
+			"ce-subject":     "42",
+			"Content-Type":   "application/json",
+		}
+		for h, v := range want {
+			if got := headers.Get(h); got != v {
+				t.Errorf("%s: %s = %q, want %q", tt.event, h, got, v)
+			}
+		}
+		if headers.Get("ce-id") == "" || headers.Get("ce-time") == "" {
+			t.Errorf("%s: ce-id and ce-time are required, got %v", tt.event, headers)
+		}
+
+		// En modo binario el body es el payload, sin sobre CloudEvents
+		var payload map[string]interface{}
+		if err := json.Unmarshal(body, &payload); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := payload["specversion"]; ok {
+			t.Errorf("%s: binary body must not be a CloudEvents envelope", tt.event)
+		}
+	}
+}
+
+func TestEncodeCloudEventsStructured(t *testing.T) {
+	d := testDelivery(models.WebhookFormatCloudEventsStructured, models.EventStatusChanged)
+	body, headers, err := d.encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := headers.Get("Content-Type"); got != cloudEventsContentType {
+		t.Fatalf("Content-Type = %q, want %q", got, cloudEventsContentType)
+	}
+	if headers.Get("ce-id") != "" {
+		t.Fatal("structured mode must not send ce-* headers")
+	}
+
+	var event CloudEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		t.Fatal(err)
+	}
+	if event.SpecVersion != CloudEventsSpecVersion || event.Type != EventTypeOrderStatusChanged ||
+		event.Source != "/dropi/co/shops/7" || event.Subject != "42" || event.DataContentType != "application/json" {
+		t.Fatalf("unexpected envelope %+v", event)
+	}
+	if event.Time != "2024-01-11T09:30:00Z" {
+		t.Fatalf("time should come from the last history item, got %q", event.Time)
+	}
+
+	var data map[string]interface{}
+	if err := json.Unmarshal(event.Data, &data); err != nil {
+		t.Fatalf("data must be the JSON payload: %v", err)
+	}
+	if data["id"] != float64(42) {
+		t.Fatalf("unexpected data %v", data)
+	}
+}
+
+func TestEncodeBatchContentType(t *testing.T) {
+	tests := []struct {
+		format models.WebhookFormat
+		want   string
+	}{
+		{models.WebhookFormatJSON, "application/json"},
+		{models.WebhookFormatCloudEventsStructured, cloudEventsBatchContentType},
+	}
+	for _, tt := range tests {
+		d := testDelivery(tt.format, models.EventStatusChanged)
+		body, headers, err := encodeBatch([]Delivery{d, d})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := headers.Get("Content-Type"); got != tt.want {
+			t.Errorf("%s: Content-Type = %q, want %q", tt.format, got, tt.want)
+		}
+		var items []json.RawMessage
+		if err := json.Unmarshal(body, &items); err != nil || len(items) != 2 {
+			t.Errorf("%s: expected a JSON array of 2 items, got %s", tt.format, body)
+		}
+	}
+	if testDelivery(models.WebhookFormatCloudEventsBinary, "").Batchable() {
+		t.Fatal("binary mode does not support batches")
+	}
+}
+
+func TestStableEventIDs(t *testing.T) {
+	a, _, _ := testDelivery(models.WebhookFormatCloudEventsBinary, "").encode()
+	_, h1, _ := testDelivery(models.WebhookFormatCloudEventsBinary, "").encode()
+	_, h2, _ := testDelivery(models.WebhookFormatCloudEventsBinary, "").encode()
+	if len(a) == 0 || h1.Get("ce-id") != h2.Get("ce-id") {
+		t.Fatal("the same transition must produce the same ce-id")
+	}
+
+	moved := testDelivery(models.WebhookFormatCloudEventsBinary, "")
+	moved.Order.History = append(moved.Order.History, models.HistoryItem{ID: 3, Status: "DEVOLUCION"})
+	_, h3, _ := moved.encode()
+	if h3.Get("ce-id") == h1.Get("ce-id") {
+		t.Fatal("a new transition must produce a new ce-id")
+	}
+}
+
+func TestResolveFormatPins(t *testing.T) {
+	t.Setenv("WEBHOOK_FORMAT_PINS", "erp/orders=cloudevents-binary, /bus/events/=cloudevents-structured,bad=xml")
+	t.Setenv("WEBHOOK_PAYLOAD_VERSION_PINS", "")
+	s := NewSender()
+
+	tests := []struct {
+		suffix string
+		format models.WebhookFormat
+		want   models.WebhookFormat
+	}{
+		{"erp/orders", "", models.WebhookFormatCloudEventsBinary},
+		{"erp/orders", models.WebhookFormatJSON, models.WebhookFormatCloudEventsBinary},
+		{"bus/events", "", models.WebhookFormatCloudEventsStructured},
+		{"bad", models.WebhookFormatCloudEventsStructured, models.WebhookFormatCloudEventsStructured},
+		{"other", "", models.WebhookFormatJSON},
+	}
+	for _, tt := range tests {
+		d := s.Resolve(Delivery{WebhookSuffix: tt.suffix, Format: tt.format})
+		if d.Format != tt.want {
+			t.Errorf("%s/%q: format = %q, want %q", tt.suffix, tt.format, d.Format, tt.want)
+		}
+	}
+
+	// El pin binario saca la entrega del batching
+	if s.Resolve(Delivery{WebhookSuffix: "erp/orders"}).Batchable() {
+		t.Fatal("pinned binary destination must not be batched")
+	}
+}
@@ -0,0 +1,139 @@
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sony/gobreaker"
+	"go.uber.org/zap"
+)
+
+// ErrDestinationUnavailable indica que el destino tiene el circuit breaker
+// abierto o alcanzó su límite de concurrencia. La entrega no se intentó y
+// puede reprogramarse más tarde sin ocupar un worker.
+var ErrDestinationUnavailable = errors.New("webhook destination unavailable")
+
+// ErrDestinationBusy el destino está en su límite de concurrencia. Envuelve
+// ErrDestinationUnavailable, pero no indica una falla del receptor: quien
+// reprograma no debe contarlo como intento.
+var ErrDestinationBusy = fmt.Errorf("%w: concurrency limit reached", ErrDestinationUnavailable)
+
+// DestinationLimits protege a cada receptor (y al pool) de un destino lento o caído.
+type DestinationLimits struct {
+	MaxConcurrency  int           // entregas simultáneas por destino
+	BreakerFailures uint32        // fallas consecutivas que abren el breaker
+	BreakerTimeout  time.Duration // tiempo que el breaker permanece abierto
+}
+
+// destinationLimitsFromEnv lee WEBHOOK_DESTINATION_MAX_CONCURRENCY,
+// WEBHOOK_BREAKER_FAILURES y WEBHOOK_BREAKER_TIMEOUT.
+func destinationLimitsFromEnv() DestinationLimits {
+	limits := DestinationLimits{
+		MaxConcurrency:  10,
+		BreakerFailures: 5,
+		BreakerTimeout:  60 * time.Second,
+	}
+
+	if v := os.Getenv("WEBHOOK_DESTINATION_MAX_CONCURRENCY"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			limits.MaxConcurrency = n
+		} else {
+			zap.L().Warn("invalid WEBHOOK_DESTINATION_MAX_CONCURRENCY, using default", zap.String("value", v))
+		}
+	}
+	if v := os.Getenv("WEBHOOK_BREAKER_FAILURES"); v != "" {
+		if n, err := strconv.ParseUint(v, 10, 32); err == nil && n > 0 {
+			limits.BreakerFailures = uint32(n)
+		} else {
+			zap.L().Warn("invalid WEBHOOK_BREAKER_FAILURES, using default", zap.String("value", v))
+		}
+	}
+	if v := os.Getenv("WEBHOOK_BREAKER_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			limits.BreakerTimeout = d
+		} else {
+			zap.L().Warn("invalid WEBHOOK_BREAKER_TIMEOUT, using default", zap.String("value", v))
+		}
+	}
+
+	return limits
+}
+
+// destination estado por URL de destino: breaker propio y semáforo de concurrencia.
+type destination struct {
+	breaker *gobreaker.CircuitBreaker
+	slots   chan struct{}
+}
+
+func (d *destination) tryAcquire() bool {
+	select {
+	case d.slots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (d *destination) release() {
+	<-d.slots
+}
+
+// destinationSet crea el estado de cada destino la primera vez que se usa.
+type destinationSet struct {
+	mu     sync.Mutex
+	limits DestinationLimits
+	byURL  map[string]*destination
+
+	// isSuccessful decide qué errores no cuentan como falla del receptor
+	// (por ejemplo un 422: el receptor está vivo, el payload no le sirve)
+	isSuccessful func(error) bool
+}
+
+func newDestinationSet(limits DestinationLimits, isSuccessful func(error) bool) *destinationSet {
+	return &destinationSet{
+		limits:       limits,
+		byURL:        make(map[string]*destination),
+		isSuccessful: isSuccessful,
+	}
+}
+
+func (s *destinationSet) get(url string) *destination {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if d, ok := s.byURL[url]; ok {
+		return d
+	}
+
+	failures := s.limits.BreakerFailures
+	d := &destination{
+		breaker: gobreaker.NewCircuitBreaker(gobreaker.Settings{
+			Name:        url,
+			MaxRequests: 1,
+			Timeout:     s.limits.BreakerTimeout,
+			ReadyToTrip: func(counts gobreaker.Counts) bool {
+				return counts.ConsecutiveFailures >= failures
+			},
+			IsSuccessful: s.isSuccessful,
+			OnStateChange: func(name string, from, to gobreaker.State) {
+				zap.L().Warn("webhook destination breaker state changed",
+					zap.String("url", name),
+					zap.String("from", from.String()),
+					zap.String("to", to.String()),
+				)
+			},
+		}),
+		slots: make(chan struct{}, s.limits.MaxConcurrency),
+	}
+	s.byURL[url] = d
+	return d
+}
+
+// isBreakerRejection indica si el breaker rechazó la llamada sin ejecutarla.
+func isBreakerRejection(err error) bool {
+	return errors.Is(err, gobreaker.ErrOpenState) || errors.Is(err, gobreaker.ErrTooManyRequests)
+}
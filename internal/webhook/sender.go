@@ -4,7 +4,9 @@ import (
     "bytes"
     "context"
     "encoding/json"
+    "errors"
     "fmt"
+    "io"
     "net/http"
     "os"
     "strings"
@@ -15,9 +17,28 @@ import (
     "go.uber.org/zap"
 )
 
+// maxResponseBody límite de lectura de la respuesta del receptor
+const maxResponseBody = 1 << 20
+
+// PayloadVersionHeader header con la versión del payload entregado
+const PayloadVersionHeader = "X-Payload-Version"
+
+// EventHeader header con el tipo de evento (order.status_changed, order.created, order.sla_breached)
+const EventHeader = "X-Webhook-Event"
+
 type Sender struct {
     httpClient *http.Client
-    baseURL    string
+    baseURL      string
+    policy       retry.Policy
+    destinations *destinationSet
+
+    // versionPins versión de payload fija por webhook_suffix; tiene prioridad
+    // sobre la pedida en el request (WEBHOOK_PAYLOAD_VERSION_PINS)
+    versionPins map[string]models.PayloadVersion
+
+    // formatPins formato fijo por webhook_suffix; tiene prioridad sobre el
+    // del request (WEBHOOK_FORMAT_PINS)
+    formatPins map[string]models.WebhookFormat
 }
 
 // NewSender construye un nuevo Webhook Sender leyendo la variable WEBHOOK_BASE_URL.
@@ -34,13 +55,100 @@ func NewSender() *Sender {
         IdleConnTimeout:     90 * time.Second,
     }
 
+    // Política de reintentos configurable con WEBHOOK_RETRY_*
+    policy, err := retry.PolicyFromEnv("WEBHOOK_RETRY")
+    if err != nil {
+        zap.L().Warn("invalid webhook retry configuration, using defaults", zap.Error(err))
+        policy = retry.DefaultPolicy()
+    }
+
+    // Los reintentos largos se reprograman en la cola del worker pool
+    // (WEBHOOK_RETRY_SCHEDULE); por defecto el sender hace un solo intento
+    // para no dormir dentro del worker.
+    if os.Getenv("WEBHOOK_RETRY_MAX_ATTEMPTS") == "" {
+        policy.MaxAttempts = 1
+    }
+
     return &Sender{
         httpClient: &http.Client{
             Timeout:   10 * time.Second,
             Transport: transport,
         },
         baseURL: strings.TrimRight(base, "/"),
+        policy:  policy,
+
+        // Breaker y límite de concurrencia por destino: WEBHOOK_DESTINATION_*, WEBHOOK_BREAKER_*
+        // Solo los errores reintentables (red, 5xx, 429) cuentan como falla del receptor.
+        destinations: newDestinationSet(destinationLimitsFromEnv(), func(err error) bool {
+            return err == nil || !policy.Retryable(err)
+        }),
+
+        versionPins: versionPinsFromEnv(),
+        formatPins:  formatPinsFromEnv(),
+    }
+}
+
+// versionPinsFromEnv lee WEBHOOK_PAYLOAD_VERSION_PINS: "suffix=version"
+// separados por coma, ej. "client123/orders=v2,legacy/hook=v1".
+func versionPinsFromEnv() map[string]models.PayloadVersion {
+    pins := make(map[string]models.PayloadVersion)
+    for _, entry := range strings.Split(os.Getenv("WEBHOOK_PAYLOAD_VERSION_PINS"), ",") {
+        entry = strings.TrimSpace(entry)
+        if entry == "" {
+            continue
+        }
+        suffix, version, ok := strings.Cut(entry, "=")
+        v := models.PayloadVersion(strings.TrimSpace(version))
+        if !ok || v == "" || !v.IsValid() {
+            zap.L().Warn("invalid payload version pin ignored", zap.String("pin", entry))
+            continue
+        }
+        pins[strings.Trim(strings.TrimSpace(suffix), "/")] = v
+    }
+    return pins
+}
+
+// formatPinsFromEnv lee WEBHOOK_FORMAT_PINS: "suffix=formato" separados por
+// coma, ej. "erp/orders=cloudevents-binary,bus/events=cloudevents-structured".
+// Así un mismo llamador entrega en formatos distintos según el destino.
+func formatPinsFromEnv() map[string]models.WebhookFormat {
+    pins := make(map[string]models.WebhookFormat)
+    for _, entry := range strings.Split(os.Getenv("WEBHOOK_FORMAT_PINS"), ",") {
+        entry = strings.TrimSpace(entry)
+        if entry == "" {
+            continue
+        }
+        suffix, format, ok := strings.Cut(entry, "=")
+        f := models.WebhookFormat(strings.TrimSpace(format))
+        if !ok || f == "" || !f.IsValid() {
+            zap.L().Warn("invalid webhook format pin ignored", zap.String("pin", entry))
+            continue
+        }
+        pins[strings.Trim(strings.TrimSpace(suffix), "/")] = f
+    }
+    return pins
+}
+
+// Resolve fija la versión de payload y el formato de la entrega: los del
+// destino si están fijados, si no los del request, si no los default. El
+// worker lo usa antes de agrupar batches, que dependen del formato.
+func (s *Sender) Resolve(d Delivery) Delivery {
+    suffix := strings.Trim(d.WebhookSuffix, "/")
+    if v, ok := s.versionPins[suffix]; ok {
+        d.Version = v
     }
+    if f, ok := s.formatPins[suffix]; ok {
+        d.Format = f
+    }
+    d.Version = d.Version.OrDefault()
+    d.Format = d.Format.OrDefault()
+    return d
+}
+
+// Retryable indica si vale la pena reintentar la entrega más tarde
+// (errores de red, 5xx, 429, ...). Un 400/404/422 no se reintenta.
+func (s *Sender) Retryable(err error) bool {
+    return s.policy.Retryable(err)
 }
 
 // BuildWebhookURL asegura que los slashes se manejen correctamente.
@@ -55,87 +163,181 @@ func (s *Sender) BuildWebhookURL(suffix string) (string, error) {
     return full, nil
 }
 
+// Delivery describe un webhook a entregar: la orden, su destino y el formato.
+type Delivery struct {
+    Order         models.DropiOrder
+    WebhookSuffix string
+    CountrySuffix string
+    Format        models.WebhookFormat
+    Options       models.PayloadOptions
+    Version       models.PayloadVersion
+    Event         models.WebhookEvent
+}
+
+// payload documento de la orden en la versión y con los bloques opcionales del
+// destino. Las órdenes nuevas tienen su propio documento.
+func (d Delivery) payload() interface{} {
+    switch d.Event {
+    case models.EventOrderCreated:
+        return d.Order.ToNewOrderPayload(d.CountrySuffix)
+    case models.EventSLABreached:
+        return d.Order.ToSLABreachPayload()
+    }
+    return d.Order.ToVersionedPayload(d.Version, d.Options)
+}
+
+// payloadVersion versión del documento entregado: la del destino para
+// order.status_changed, la propia del evento para los demás.
+func (d Delivery) payloadVersion() models.PayloadVersion {
+    return d.Event.OrDefault().PayloadVersion(d.Version)
+}
+
+// encode serializa la entrega según el formato del destino y retorna el body
+// junto con los headers propios del formato.
+func (d Delivery) encode() ([]byte, http.Header, error) {
+    // Usar el método del modelo para convertir
+    payload := d.payload()
+
+    data, err := json.Marshal(payload)
+    if err != nil {
+        return nil, nil, fmt.Errorf("error marshaling webhook payload: %w", err)
+    }
+
+    headers := http.Header{}
+    headers.Set(PayloadVersionHeader, string(d.payloadVersion()))
+    headers.Set(EventHeader, string(d.Event.OrDefault()))
+
+    switch d.Format.OrDefault() {
+    case models.WebhookFormatCloudEventsBinary:
+        event := newDeliveryEvent(d, data)
+        event.applyBinaryHeaders(headers)
+        return data, headers, nil
+
+    case models.WebhookFormatCloudEventsStructured:
+        event := newDeliveryEvent(d, data)
+        body, err := json.Marshal(event)
+        if err != nil {
+            return nil, nil, fmt.Errorf("error marshaling cloudevent: %w", err)
+        }
+        headers.Set("Content-Type", cloudEventsContentType)
+        return body, headers, nil
+
+    default:
+        headers.Set("Content-Type", "application/json")
+        return data, headers, nil
+    }
+}
+
 // SendWebhook envía un webhook a un endpoint dinámico.
-func (s *Sender) SendWebhook(order models.DropiOrder, webhookSuffix string) error {
-    url, err := s.BuildWebhookURL(webhookSuffix)
+func (s *Sender) SendWebhook(ctx context.Context, d Delivery) error {
+    d = s.Resolve(d)
+    order := d.Order
+
+    url, err := s.BuildWebhookURL(d.WebhookSuffix)
     if err != nil {
         return err
     }
 
-    // Usar el método del modelo para convertir
-    payload := order.ToWebhookPayload()
-
-    body, err := json.Marshal(payload)
+    body, headers, err := d.encode()
     if err != nil {
-        return fmt.Errorf("error marshaling webhook payload: %w", err)
+        return err
     }
 
     zap.L().Info("sending webhook",
         zap.String("url", url),
         zap.Int64("order_id", order.ID),
         zap.String("status", order.Status),
+        zap.String("format", string(d.Format.OrDefault())),
+        zap.String("payload_version", string(d.payloadVersion())),
+        zap.String("event", string(d.Event.OrDefault())),
     )
 
-    ctx := context.Background()
-    attemptCount := 0
+    _, err = s.deliver(ctx, url, body, headers, zap.Int64("order_id", order.ID))
+    return err
+}
 
-    err = retry.WithRetry(ctx, 3, time.Second, func() error {
-        attemptCount++
+// deliver hace el POST con reintentos según la política del sender y retorna el
+// body de la respuesta exitosa. Los códigos no reintentables (400, 404, 422, ...)
+// cortan los reintentos de inmediato. Si el destino tiene el breaker abierto o
+// está en su límite de concurrencia (ErrDestinationBusy) retorna
+// ErrDestinationUnavailable sin bloquear al worker. fields identifica la entrega en los logs.
+func (s *Sender) deliver(ctx context.Context, url string, body []byte, headers http.Header, fields ...zap.Field) ([]byte, error) {
+    logFields := func(extra ...zap.Field) []zap.Field {
+        out := append([]zap.Field{zap.String("url", url)}, fields...)
+        return append(out, extra...)
+    }
 
-        zap.L().Info("webhook attempt",
-            zap.String("url", url),
-            zap.Int64("order_id", order.ID),
-            zap.Int("attempt", attemptCount),
-        )
+    dest := s.destinations.get(url)
+    if !dest.tryAcquire() {
+        zap.L().Warn("webhook destination at concurrency limit", logFields()...)
+        return nil, ErrDestinationBusy
+    }
+    defer dest.release()
 
-        req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(body))
-        if err != nil {
-            return fmt.Errorf("error creating webhook request: %w", err)
-        }
+    attemptCount := 0
+    var respBody []byte
 
-        req.Header.Set("Content-Type", "application/json")
-        req.Header.Set("X-Retry-Attempt", fmt.Sprintf("%d", attemptCount))
+    err := s.policy.Do(ctx, func(attempt int) error {
+        attemptCount = attempt
 
-        resp, err := s.httpClient.Do(req)
+        zap.L().Info("webhook attempt", logFields(zap.Int("attempt", attemptCount))...)
+
+        result, err := dest.breaker.Execute(func() (interface{}, error) {
+            return s.post(ctx, url, body, headers, attempt)
+        })
+        if isBreakerRejection(err) {
+            zap.L().Warn("webhook destination circuit open", logFields(zap.Int("attempt", attemptCount))...)
+            return retry.Permanent(fmt.Errorf("%w: %v", ErrDestinationUnavailable, err))
+        }
         if err != nil {
-            zap.L().Warn("webhook request failed",
-                zap.String("url", url),
-                zap.Int64("order_id", order.ID),
+            zap.L().Warn("webhook failed", logFields(
                 zap.Int("attempt", attemptCount),
                 zap.Error(err),
-            )
+            )...)
             return err
         }
-        defer resp.Body.Close()
 
-        if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-            zap.L().Info("webhook sent successfully",
-                zap.String("url", url),
-                zap.Int64("order_id", order.ID),
-                zap.Int("status_code", resp.StatusCode),
-                zap.Int("attempt", attemptCount),
-            )
-            return nil
+        respBody = result.([]byte)
+        zap.L().Info("webhook sent successfully", logFields(zap.Int("attempt", attemptCount))...)
+        return nil
+    })
+
+    if err != nil {
+        if !errors.Is(err, ErrDestinationUnavailable) {
+            zap.L().Error("webhook failed, giving up", logFields(
+                zap.Int("total_attempts", attemptCount),
+                zap.Error(err),
+            )...)
         }
+        return nil, err
+    }
 
-        err = fmt.Errorf("webhook failed with status %d", resp.StatusCode)
-        zap.L().Warn("webhook failed",
-            zap.String("url", url),
-            zap.Int64("order_id", order.ID),
-            zap.Int("status_code", resp.StatusCode),
-            zap.Int("attempt", attemptCount),
-        )
-        return err
-    })
+    return respBody, nil
+}
 
+// post hace un único intento de entrega. Retorna el body de la respuesta 2xx
+// (solo interesa para resultados por item de un batch).
+func (s *Sender) post(ctx context.Context, url string, body []byte, headers http.Header, attempt int) ([]byte, error) {
+    req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(body))
     if err != nil {
-        zap.L().Error("webhook failed after all retries",
-            zap.String("url", url),
-            zap.Int64("order_id", order.ID),
-            zap.Int("total_attempts", attemptCount),
-            zap.Error(err),
-        )
+        return nil, retry.Permanent(fmt.Errorf("error creating webhook request: %w", err))
     }
 
-    return err
+    for key, values := range headers {
+        req.Header[key] = values
+    }
+    req.Header.Set("X-Retry-Attempt", fmt.Sprintf("%d", attempt))
+
+    resp, err := s.httpClient.Do(req)
+    if err != nil {
+        return nil, err
+    }
+    defer resp.Body.Close()
+
+    if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+        respBody, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+        return respBody, nil
+    }
+
+    return nil, fmt.Errorf("webhook failed: %w", retry.NewStatusError(resp))
 }
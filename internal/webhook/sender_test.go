@@ -0,0 +1,43 @@
+package webhook
+
+import (
+	"testing"
+
+	"github.com/juancollazo-ch/dropi-order-status-service/internal/models"
+)
+
+func TestEncodePayloadVersionHeader(t *testing.T) {
+	tests := []struct {
+		event   models.WebhookEvent
+		version models.PayloadVersion
+		want    string
+	}{
+		{"", "", "v1"},
+		{models.EventStatusChanged, models.PayloadV2, "v2"},
+		// order.created y order.sla_breached tienen su propio documento: no
+		// heredan la versión del destino
+		{models.EventOrderCreated, models.PayloadV2, "v1"},
+		{models.EventOrderCreated, "", "v1"},
+		{models.EventSLABreached, models.PayloadV2, "v1"},
+	}
+	for _, tt := range tests {
+		d := Delivery{Event: tt.event, Version: tt.version, CountrySuffix: "co"}
+		d.Order.ID = 1
+
+		_, headers, err := d.encode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := headers.Get(PayloadVersionHeader); got != tt.want {
+			t.Errorf("%s/%s: %s = %q, want %q", tt.event, tt.version, PayloadVersionHeader, got, tt.want)
+		}
+
+		_, batchHeaders, err := encodeBatch([]Delivery{d})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := batchHeaders.Get(PayloadVersionHeader); got != tt.want {
+			t.Errorf("%s/%s batch: %s = %q, want %q", tt.event, tt.version, PayloadVersionHeader, got, tt.want)
+		}
+	}
+}
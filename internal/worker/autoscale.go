@@ -0,0 +1,185 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PoolConfig tamaño del pool y límites del autoscaler.
+type PoolConfig struct {
+	Size      int           // workers al iniciar
+	Min       int           // mínimo permitido (autoscaler y resize manual)
+	Max       int           // máximo permitido (autoscaler y resize manual)
+	Autoscale bool          // ajustar el tamaño según cola y latencia
+	Interval  time.Duration // cada cuánto evalúa el autoscaler
+}
+
+// PoolConfigFromEnv lee WORKER_POOL_SIZE, WORKER_POOL_MIN, WORKER_POOL_MAX,
+// WORKER_AUTOSCALE y WORKER_AUTOSCALE_INTERVAL.
+func PoolConfigFromEnv() PoolConfig {
+	cfg := PoolConfig{
+		Size:     50,
+		Min:      5,
+		Max:      200,
+		Interval: 15 * time.Second,
+	}
+
+	ints := []struct {
+		name string
+		dst  *int
+	}{
+		{"WORKER_POOL_SIZE", &cfg.Size},
+		{"WORKER_POOL_MIN", &cfg.Min},
+		{"WORKER_POOL_MAX", &cfg.Max},
+	}
+	for _, v := range ints {
+		raw := os.Getenv(v.name)
+		if raw == "" {
+			continue
+		}
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			slog.Warn("variable de pool inválida, usando default", "name", v.name, "value", raw, "default", *v.dst)
+			continue
+		}
+		*v.dst = n
+	}
+
+	if cfg.Max < cfg.Min {
+		cfg.Max = cfg.Min
+	}
+	cfg.Size = clamp(cfg.Size, cfg.Min, cfg.Max)
+
+	if v := os.Getenv("WORKER_AUTOSCALE"); v != "" {
+		enabled, err := strconv.ParseBool(strings.TrimSpace(v))
+		if err != nil {
+			slog.Warn("WORKER_AUTOSCALE inválido, autoscaler deshabilitado", "value", v)
+		}
+		cfg.Autoscale = enabled
+	}
+	if v := os.Getenv("WORKER_AUTOSCALE_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			cfg.Interval = d
+		} else {
+			slog.Warn("WORKER_AUTOSCALE_INTERVAL inválido, usando default", "value", v, "default", cfg.Interval)
+		}
+	}
+
+	return cfg
+}
+
+// PoolStats estado actual del pool para el endpoint de administración.
+type PoolStats struct {
+	Workers        int   `json:"workers"`
+	MinWorkers     int   `json:"min_workers"`
+	MaxWorkers     int   `json:"max_workers"`
+	Autoscale      bool  `json:"autoscale"`
+	Busy           int   `json:"busy"`
+	QueueDepth     int   `json:"queue_depth"` // solo tareas listas
+	QueueWaiting   int   `json:"queue_waiting"`
+	QueueScheduled int   `json:"queue_scheduled"`
+	AvgLatencyMs   int64 `json:"avg_delivery_latency_ms"`
+}
+
+// latencyTracker promedio móvil exponencial de la duración de las entregas.
+type latencyTracker struct {
+	mu  sync.Mutex
+	avg time.Duration
+}
+
+func (l *latencyTracker) observe(d time.Duration) {
+	const alpha = 0.2
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.avg == 0 {
+		l.avg = d
+		return
+	}
+	l.avg = time.Duration(alpha*float64(d) + (1-alpha)*float64(l.avg))
+}
+
+func (l *latencyTracker) value() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.avg
+}
+
+// desiredWorkers estima cuántos workers hacen falta para vaciar la cola en un
+// intervalo dada la latencia promedio de entrega (ley de Little): los workers
+// ocupados más los necesarios para la cola pendiente. Crece de inmediato y se
+// achica como máximo un 25% por intervalo para no oscilar.
+func desiredWorkers(current, busy, depth int, latency, interval time.Duration, lo, hi int) int {
+	if latency <= 0 {
+		latency = 100 * time.Millisecond
+	}
+
+	perWorker := float64(interval) / float64(latency) // entregas por worker en un intervalo
+	needed := busy + int(math.Ceil(float64(depth)/perWorker))
+
+	if needed < current {
+		floor := current - int(math.Ceil(float64(current)*0.25))
+		if needed < floor {
+			needed = floor
+		}
+	}
+
+	return clamp(needed, lo, hi)
+}
+
+// runAutoscaler ajusta el pool cada Interval hasta que el context termine.
+func (wp *WorkerPool) runAutoscaler(ctx context.Context) {
+	ticker := time.NewTicker(wp.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if !wp.AutoscaleEnabled() {
+			continue
+		}
+
+		stats := wp.Stats()
+		desired := desiredWorkers(
+			stats.Workers,
+			stats.Busy,
+			stats.QueueDepth,
+			wp.latency.value(),
+			wp.config.Interval,
+			stats.MinWorkers,
+			stats.MaxWorkers,
+		)
+
+		if desired != stats.Workers {
+			slog.Info("autoscaler ajustando pool",
+				"from", stats.Workers,
+				"to", desired,
+				"queue_depth", stats.QueueDepth,
+				"busy", stats.Busy,
+				"avg_latency_ms", stats.AvgLatencyMs,
+			)
+			wp.Resize(desired)
+		}
+	}
+}
+
+func clamp(n, lo, hi int) int {
+	if n < lo {
+		return lo
+	}
+	if n > hi {
+		return hi
+	}
+	return n
+}
@@ -0,0 +1,126 @@
+package worker
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/juancollazo-ch/dropi-order-status-service/internal/models"
+)
+
+// Priority carril de la cola. Los carriles se atienden con round robin
+// ponderado: high no espera detrás de un backfill de miles de tareas low,
+// pero low nunca queda sin atender.
+type Priority int
+
+const (
+	PriorityHigh Priority = iota
+	PriorityNormal
+	PriorityLow
+
+	numPriorities = 3
+)
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityHigh:
+		return "high"
+	case PriorityLow:
+		return "low"
+	default:
+		return "normal"
+	}
+}
+
+// PriorityConfig asigna un carril según el status de la orden.
+type PriorityConfig struct {
+	ByStatus map[string]Priority
+	Weights  [numPriorities]int // tareas atendidas por ronda en cada carril
+}
+
+// DefaultPriorityConfig entregas, devoluciones y novedades primero; guía
+// generada al final.
+func DefaultPriorityConfig() PriorityConfig {
+	return PriorityConfig{
+		ByStatus: map[string]Priority{
+			"ENTREGADO":     PriorityHigh,
+			"DEVOLUCION":    PriorityHigh,
+			"NOVEDAD":       PriorityHigh,
+			"GUIA_GENERADA": PriorityLow,
+		},
+		Weights: [numPriorities]int{6, 3, 1},
+	}
+}
+
+// PriorityConfigFromEnv lee WEBHOOK_PRIORITY_HIGH y WEBHOOK_PRIORITY_LOW (listas
+// de status separadas por coma, reemplazan las del default) y
+// WEBHOOK_PRIORITY_WEIGHTS ("high,normal,low", ej. "6,3,1").
+func PriorityConfigFromEnv() PriorityConfig {
+	cfg := DefaultPriorityConfig()
+
+	high, highSet := os.LookupEnv("WEBHOOK_PRIORITY_HIGH")
+	low, lowSet := os.LookupEnv("WEBHOOK_PRIORITY_LOW")
+	if highSet || lowSet {
+		byStatus := make(map[string]Priority)
+		for p, prio := range cfg.ByStatus {
+			if (prio == PriorityHigh && !highSet) || (prio == PriorityLow && !lowSet) {
+				byStatus[p] = prio
+			}
+		}
+		for _, status := range splitStatuses(high) {
+			byStatus[status] = PriorityHigh
+		}
+		for _, status := range splitStatuses(low) {
+			byStatus[status] = PriorityLow
+		}
+		cfg.ByStatus = byStatus
+	}
+
+	if v := os.Getenv("WEBHOOK_PRIORITY_WEIGHTS"); v != "" {
+		weights, err := parseWeights(v)
+		if err != nil {
+			slog.Warn("WEBHOOK_PRIORITY_WEIGHTS inválido, usando default", "value", v, "error", err)
+		} else {
+			cfg.Weights = weights
+		}
+	}
+
+	return cfg
+}
+
+// priorityFor carril para el status canónico; los status no configurados van a normal.
+func (c PriorityConfig) priorityFor(status string) Priority {
+	if p, ok := c.ByStatus[string(models.NormalizeStatus(status))]; ok {
+		return p
+	}
+	return PriorityNormal
+}
+
+func splitStatuses(v string) []string {
+	var out []string
+	for _, part := range strings.Split(v, ",") {
+		if status := models.NormalizeStatus(part); status != "" {
+			out = append(out, string(status))
+		}
+	}
+	return out
+}
+
+func parseWeights(v string) ([numPriorities]int, error) {
+	var weights [numPriorities]int
+
+	parts := strings.Split(v, ",")
+	if len(parts) != numPriorities {
+		return weights, fmt.Errorf("expected %d comma separated weights", numPriorities)
+	}
+	for i, part := range parts {
+		n, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || n < 1 {
+			return weights, fmt.Errorf("weight %q must be a positive integer", part)
+		}
+		weights[i] = n
+	}
+	return weights, nil
+}
@@ -0,0 +1,205 @@
+package worker
+
+import (
+	"context"
+	"sync"
+)
+
+// taskQueue cola de tareas con orden por clave: las tareas con la misma clave
+// (destino + orden) se entregan de a una y en orden de llegada, mientras que
+// tareas de claves distintas se procesan en paralelo.
+//
+// Una clave está activa desde que su primera tarea entra a la cola hasta que
+// el worker la marca como terminada (done). Las tareas que llegan mientras la
+// clave está activa esperan en keys[clave] y pasan a ready de a una, en el
+// carril de su prioridad. Como una clave nunca tiene más de una tarea lista o
+// en proceso, la prioridad no puede invertir el orden de una misma orden.
+//
+// Solo las tareas listas cuentan para capacity (backpressure y autoscaling).
+// Las que esperan su clave tienen el mismo límite por separado y las
+// reprogramadas van a un almacén propio (delayedLimits), así un destino caído
+// no llena la cola del resto.
+type taskQueue struct {
+	mu       sync.Mutex
+	ready    [numPriorities][]WorkerTask
+	keys     map[string][]WorkerTask
+	readyLen int // tareas en ready
+	waiting  int // tareas esperando que su clave se libere
+	capacity int
+
+	// tareas reprogramadas esperando su NotBefore
+	delayed       delayedTasks
+	delayedByDest map[string]int
+	delayedLimits DelayedLimits
+
+	// round robin ponderado (smooth WRR) entre carriles con tareas
+	weights [numPriorities]int
+	current [numPriorities]int
+
+	// changed se cierra y reemplaza en cada cambio para despertar a quienes esperan
+	changed chan struct{}
+}
+
+func newTaskQueue(capacity int) *taskQueue {
+	return &taskQueue{
+		keys:          make(map[string][]WorkerTask),
+		capacity:      capacity,
+		delayedByDest: make(map[string]int),
+		delayedLimits: DefaultDelayedLimits(),
+		weights:       DefaultPriorityConfig().Weights,
+		changed:       make(chan struct{}),
+	}
+}
+
+func (q *taskQueue) setWeights(weights [numPriorities]int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.weights = weights
+}
+
+// enqueueReady agrega la tarea a su carril. Requiere q.mu.
+func (q *taskQueue) enqueueReady(task WorkerTask) {
+	p := task.Priority
+	if p < 0 || p >= numPriorities {
+		p = PriorityNormal
+	}
+	q.ready[p] = append(q.ready[p], task)
+	q.readyLen++
+	q.broadcast()
+}
+
+// nextLane elige el carril a atender con smooth weighted round robin entre los
+// carriles no vacíos. Retorna -1 si no hay tareas listas. Requiere q.mu.
+func (q *taskQueue) nextLane() int {
+	best, total := -1, 0
+	for i := range q.ready {
+		if len(q.ready[i]) == 0 {
+			continue
+		}
+		q.current[i] += q.weights[i]
+		total += q.weights[i]
+		if best == -1 || q.current[i] > q.current[best] {
+			best = i
+		}
+	}
+	if best >= 0 {
+		q.current[best] -= total
+	}
+	return best
+}
+
+// readyByPriority tareas listas por carril.
+func (q *taskQueue) readyByPriority() map[string]int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	out := make(map[string]int, numPriorities)
+	for i := range q.ready {
+		out[Priority(i).String()] = len(q.ready[i])
+	}
+	return out
+}
+
+// broadcast despierta a los workers y productores en espera. Requiere q.mu.
+func (q *taskQueue) broadcast() {
+	close(q.changed)
+	q.changed = make(chan struct{})
+}
+
+// push encola la tarea o retorna ErrQueueFull. Una tarea lista se rechaza
+// con capacity tareas listas; una que espera su clave, con capacity esperando.
+func (q *taskQueue) push(task WorkerTask) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	key := task.key()
+	if pending, active := q.keys[key]; active {
+		if q.waiting >= q.capacity {
+			return ErrQueueFull
+		}
+		q.waiting++
+		q.keys[key] = append(pending, task)
+		return nil
+	}
+
+	if q.readyLen >= q.capacity {
+		return ErrQueueFull
+	}
+	q.keys[key] = nil
+	q.enqueueReady(task)
+	return nil
+}
+
+// pop espera la próxima tarea lista. Retorna false si el context expira.
+func (q *taskQueue) pop(ctx context.Context) (WorkerTask, bool) {
+	for {
+		q.mu.Lock()
+		if lane := q.nextLane(); lane >= 0 {
+			task := q.ready[lane][0]
+			q.ready[lane][0] = WorkerTask{}
+			q.ready[lane] = q.ready[lane][1:]
+			q.readyLen--
+			q.broadcast()
+			q.mu.Unlock()
+			return task, true
+		}
+		wait := q.changed
+		q.mu.Unlock()
+
+		select {
+		case <-wait:
+		case <-ctx.Done():
+			return WorkerTask{}, false
+		}
+	}
+}
+
+// done libera la clave de la tarea y habilita la siguiente con la misma clave.
+func (q *taskQueue) done(task WorkerTask) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	key := task.key()
+	pending := q.keys[key]
+	if len(pending) == 0 {
+		delete(q.keys, key)
+		return
+	}
+
+	q.keys[key] = pending[1:]
+	q.waiting--
+	q.enqueueReady(pending[0])
+}
+
+// waitSpace espera a que haya espacio en la cola o a que el context expire.
+func (q *taskQueue) waitSpace(ctx context.Context) error {
+	for {
+		q.mu.Lock()
+		if q.readyLen < q.capacity && q.waiting < q.capacity {
+			q.mu.Unlock()
+			return nil
+		}
+		wait := q.changed
+		q.mu.Unlock()
+
+		select {
+		case <-wait:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// len tareas listas esperando un worker.
+func (q *taskQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.readyLen
+}
+
+// waitingLen tareas esperando que termine la entrega anterior de su clave.
+func (q *taskQueue) waitingLen() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.waiting
+}
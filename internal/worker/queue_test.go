@@ -0,0 +1,183 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func task(suffix string, orderID int64, p Priority) WorkerTask {
+	t := WorkerTask{WebhookSuffix: suffix, Priority: p}
+	t.Order.ID = orderID
+	return t
+}
+
+func popNow(t *testing.T, q *taskQueue) (WorkerTask, bool) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	return q.pop(ctx)
+}
+
+func TestQueueSameKeyIsSequential(t *testing.T) {
+	q := newTaskQueue(10)
+
+	first := task("a", 1, PriorityNormal)
+	second := task("a", 1, PriorityHigh)
+	second.Attempt = 7 // para distinguirla
+	if err := q.push(first); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.push(second); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.push(task("a", 2, PriorityLow)); err != nil {
+		t.Fatal(err)
+	}
+
+	got, _ := popNow(t, q)
+	if got.Order.ID != 1 || got.Attempt != 0 {
+		t.Fatalf("expected first task of order 1, got %+v", got)
+	}
+	// La segunda tarea de la orden 1 espera aunque sea high
+	got, _ = popNow(t, q)
+	if got.Order.ID != 2 {
+		t.Fatalf("expected order 2 while order 1 is active, got %d", got.Order.ID)
+	}
+	if _, ok := popNow(t, q); ok {
+		t.Fatal("key-blocked task must not be ready before done")
+	}
+
+	q.done(first)
+	got, ok := popNow(t, q)
+	if !ok || got.Attempt != 7 {
+		t.Fatalf("expected second task of order 1 after done, got %+v", got)
+	}
+}
+
+func TestQueueWeightedRoundRobin(t *testing.T) {
+	q := newTaskQueue(100)
+	q.setWeights([numPriorities]int{6, 3, 1})
+
+	var id int64
+	for p := PriorityHigh; p <= PriorityLow; p++ {
+		for i := 0; i < 20; i++ {
+			id++
+			if err := q.push(task("a", id, p)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	counts := map[Priority]int{}
+	for i := 0; i < 10; i++ {
+		got, ok := popNow(t, q)
+		if !ok {
+			t.Fatal("queue drained early")
+		}
+		counts[got.Priority]++
+	}
+	if counts[PriorityHigh] != 6 || counts[PriorityNormal] != 3 || counts[PriorityLow] != 1 {
+		t.Fatalf("unexpected lane distribution over one round: %v", counts)
+	}
+}
+
+func TestQueueLowLaneIsServedAlone(t *testing.T) {
+	q := newTaskQueue(10)
+	if err := q.push(task("a", 1, PriorityLow)); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := popNow(t, q); !ok || got.Priority != PriorityLow {
+		t.Fatalf("expected low task, got %+v ok=%v", got, ok)
+	}
+}
+
+func TestQueueBackpressureCountsReadyOnly(t *testing.T) {
+	q := newTaskQueue(2)
+
+	// Tareas reprogramadas de un destino caído no ocupan capacidad
+	for i := int64(1); i <= 5; i++ {
+		parked := task("dead", i, PriorityNormal)
+		parked.NotBefore = time.Now().Add(time.Hour)
+		if err := q.schedule(parked); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := q.push(task("ok", 1, PriorityNormal)); err != nil {
+		t.Fatalf("push with only delayed tasks: %v", err)
+	}
+	if err := q.push(task("ok", 2, PriorityNormal)); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.push(task("ok", 3, PriorityNormal)); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("expected ErrQueueFull with 2 ready tasks, got %v", err)
+	}
+
+	// Una tarea que espera su clave tampoco cuenta como lista
+	if err := q.push(task("ok", 1, PriorityNormal)); err != nil {
+		t.Fatalf("key-blocked push: %v", err)
+	}
+	if q.len() != 2 || q.waitingLen() != 1 || q.scheduledLen() != 5 {
+		t.Fatalf("ready=%d waiting=%d scheduled=%d", q.len(), q.waitingLen(), q.scheduledLen())
+	}
+}
+
+func TestQueueDelayedLimits(t *testing.T) {
+	q := newTaskQueue(10)
+	q.setDelayedLimits(DelayedLimits{Max: 3, PerDestination: 2})
+
+	later := time.Now().Add(time.Hour)
+	schedule := func(suffix string, id int64) error {
+		t := task(suffix, id, PriorityNormal)
+		t.NotBefore = later
+		return q.schedule(t)
+	}
+
+	if err := schedule("a", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := schedule("a", 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := schedule("a", 3); !errors.Is(err, ErrDelayedFull) {
+		t.Fatalf("expected per-destination limit, got %v", err)
+	}
+	if err := schedule("b", 1); err != nil {
+		t.Fatalf("other destination must not be affected: %v", err)
+	}
+	if err := schedule("c", 1); !errors.Is(err, ErrDelayedFull) {
+		t.Fatalf("expected global limit, got %v", err)
+	}
+}
+
+func TestQueuePromoteDueInNotBeforeOrder(t *testing.T) {
+	q := newTaskQueue(10)
+	now := time.Now()
+
+	for i, offset := range []time.Duration{3 * time.Second, time.Second, 2 * time.Second, time.Hour} {
+		d := task("a", int64(i+1), PriorityNormal)
+		d.NotBefore = now.Add(offset)
+		if err := q.schedule(d); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	next, _ := q.promoteDue(now.Add(5 * time.Second))
+	if !next.Equal(now.Add(time.Hour)) {
+		t.Fatalf("next due = %v, want the 1h task", next.Sub(now))
+	}
+	if q.len() != 3 || q.scheduledLen() != 1 {
+		t.Fatalf("ready=%d scheduled=%d", q.len(), q.scheduledLen())
+	}
+
+	var order []int64
+	for i := 0; i < 3; i++ {
+		got, _ := popNow(t, q)
+		order = append(order, got.Order.ID)
+	}
+	if order[0] != 2 || order[1] != 3 || order[2] != 1 {
+		t.Fatalf("promoted out of NotBefore order: %v", order)
+	}
+}
@@ -0,0 +1,236 @@
+package worker
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultRetrySchedule esperas entre reintentos encolados: un receptor en
+// mantenimiento tiene horas para volver antes de perder la entrega.
+var DefaultRetrySchedule = []time.Duration{
+	time.Minute,
+	5 * time.Minute,
+	30 * time.Minute,
+	2 * time.Hour,
+}
+
+// RetryScheduleFromEnv lee WEBHOOK_RETRY_SCHEDULE ("1m,5m,30m,2h"). Una lista
+// vacía ("none") deshabilita los reintentos encolados.
+func RetryScheduleFromEnv() []time.Duration {
+	v, ok := os.LookupEnv("WEBHOOK_RETRY_SCHEDULE")
+	if !ok {
+		return DefaultRetrySchedule
+	}
+
+	schedule, err := parseSchedule(v)
+	if err != nil {
+		slog.Warn("WEBHOOK_RETRY_SCHEDULE inválido, usando default", "value", v, "error", err)
+		return DefaultRetrySchedule
+	}
+	return schedule
+}
+
+func parseSchedule(v string) ([]time.Duration, error) {
+	v = strings.TrimSpace(v)
+	if v == "" || strings.EqualFold(v, "none") {
+		return nil, nil
+	}
+
+	var schedule []time.Duration
+	for _, part := range strings.Split(v, ",") {
+		d, err := time.ParseDuration(strings.TrimSpace(part))
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid delay %q", part)
+		}
+		schedule = append(schedule, d)
+	}
+	return schedule, nil
+}
+
+// delayedTasks min-heap de tareas ordenadas por NotBefore.
+type delayedTasks []WorkerTask
+
+func (h delayedTasks) Len() int            { return len(h) }
+func (h delayedTasks) Less(i, j int) bool  { return h[i].NotBefore.Before(h[j].NotBefore) }
+func (h delayedTasks) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *delayedTasks) Push(x interface{}) { *h = append(*h, x.(WorkerTask)) }
+func (h *delayedTasks) Pop() interface{} {
+	old := *h
+	n := len(old)
+	task := old[n-1]
+	old[n-1] = WorkerTask{}
+	*h = old[:n-1]
+	return task
+}
+
+// ErrDelayedFull el almacén de tareas reprogramadas (o la cuota del destino)
+// está lleno; la tarea no puede esperar su próximo intento.
+var ErrDelayedFull = errors.New("delayed task store is full")
+
+// DelayedLimits acota las tareas reprogramadas (reintentos y destinos no
+// disponibles), separadas de la capacidad de la cola.
+type DelayedLimits struct {
+	Max            int // total de tareas reprogramadas
+	PerDestination int // tareas reprogramadas por webhook_suffix
+}
+
+// DefaultDelayedLimits un receptor caído puede retener como máximo 1000 tareas.
+func DefaultDelayedLimits() DelayedLimits {
+	return DelayedLimits{Max: 10000, PerDestination: 1000}
+}
+
+// DelayedLimitsFromEnv lee WEBHOOK_DELAYED_MAX y WEBHOOK_DELAYED_PER_DESTINATION.
+func DelayedLimitsFromEnv() DelayedLimits {
+	limits := DefaultDelayedLimits()
+
+	ints := []struct {
+		name string
+		dst  *int
+	}{
+		{"WEBHOOK_DELAYED_MAX", &limits.Max},
+		{"WEBHOOK_DELAYED_PER_DESTINATION", &limits.PerDestination},
+	}
+	for _, v := range ints {
+		raw := os.Getenv(v.name)
+		if raw == "" {
+			continue
+		}
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			slog.Warn("límite de reprogramadas inválido, usando default", "name", v.name, "value", raw, "default", *v.dst)
+			continue
+		}
+		*v.dst = n
+	}
+
+	return limits
+}
+
+func (q *taskQueue) setDelayedLimits(limits DelayedLimits) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.delayedLimits = limits
+}
+
+// schedule guarda la tarea hasta task.NotBefore. La tarea conserva su clave,
+// así las entregas siguientes de la misma orden esperan detrás de ella.
+// Retorna ErrDelayedFull si se alcanzó el límite total o el de su destino;
+// en ese caso quien llama debe liberar la clave (done).
+func (q *taskQueue) schedule(task WorkerTask) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.delayed) >= q.delayedLimits.Max ||
+		q.delayedByDest[task.WebhookSuffix] >= q.delayedLimits.PerDestination {
+		return ErrDelayedFull
+	}
+
+	q.delayedByDest[task.WebhookSuffix]++
+	heap.Push(&q.delayed, task)
+	q.broadcast()
+	return nil
+}
+
+// promoteDue mueve a ready las tareas cuyo NotBefore ya pasó. Retorna cuándo
+// vence la próxima (zero si no hay) y el canal que avisa el próximo cambio.
+func (q *taskQueue) promoteDue(now time.Time) (time.Time, <-chan struct{}) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for len(q.delayed) > 0 && !q.delayed[0].NotBefore.After(now) {
+		task := heap.Pop(&q.delayed).(WorkerTask)
+		if q.delayedByDest[task.WebhookSuffix]--; q.delayedByDest[task.WebhookSuffix] <= 0 {
+			delete(q.delayedByDest, task.WebhookSuffix)
+		}
+		q.enqueueReady(task)
+	}
+
+	var next time.Time
+	if len(q.delayed) > 0 {
+		next = q.delayed[0].NotBefore
+	}
+	return next, q.changed
+}
+
+// scheduledLen tareas esperando su NotBefore.
+func (q *taskQueue) scheduledLen() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.delayed)
+}
+
+// runScheduler libera las tareas reprogramadas cuando vencen.
+func (wp *WorkerPool) runScheduler(ctx context.Context) {
+	for {
+		next, changed := wp.queue.promoteDue(time.Now())
+
+		var timer *time.Timer
+		var due <-chan time.Time
+		if !next.IsZero() {
+			timer = time.NewTimer(time.Until(next))
+			due = timer.C
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-changed:
+		case <-due:
+		}
+
+		if timer != nil {
+			timer.Stop()
+		}
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// reschedule reintenta la tarea más tarde según el schedule del pool. El
+// Retry-After del receptor se respeta si es mayor. Retorna false si ya no
+// quedan reintentos.
+func (wp *WorkerPool) reschedule(task WorkerTask, retryAfter time.Duration) bool {
+	if task.Attempt >= len(wp.retrySchedule) {
+		return false
+	}
+
+	delay := wp.retrySchedule[task.Attempt]
+	if retryAfter > delay {
+		delay = retryAfter
+	}
+
+	task.Attempt++
+	task.NotBefore = time.Now().Add(delay)
+
+	slog.Warn("webhook reprogramado",
+		"order_id", task.Order.ID,
+		"suffix", task.WebhookSuffix,
+		"attempt", task.Attempt,
+		"not_before", task.NotBefore,
+	)
+
+	wp.delay(task)
+	return true
+}
+
+// delay guarda la tarea reprogramada; si el almacén (o la cuota de su
+// destino) está lleno la tarea se descarta y su clave se libera.
+func (wp *WorkerPool) delay(task WorkerTask) {
+	if err := wp.queue.schedule(task); err != nil {
+		slog.Error("webhook descartado: reprogramadas llenas",
+			"order_id", task.Order.ID,
+			"suffix", task.WebhookSuffix,
+			"attempt", task.Attempt,
+			"parked", task.Parked,
+			"error", err,
+		)
+		wp.queue.done(task)
+	}
+}
@@ -2,21 +2,157 @@ package worker
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/juancollazo-ch/dropi-order-status-service/internal/models"
+	"github.com/juancollazo-ch/dropi-order-status-service/internal/retry"
 	"github.com/juancollazo-ch/dropi-order-status-service/internal/webhook"
 )
 
 type WorkerTask struct {
 	Order         models.DropiOrder
 	WebhookSuffix string
+	CountrySuffix string
+	Format        models.WebhookFormat
+	Options       models.PayloadOptions
+	Version       models.PayloadVersion
+	Event         models.WebhookEvent
+
+	// Priority carril de la cola; el pool lo asigna según el status al encolar
+	Priority Priority
+
+	// Escalated transición marcada por las reglas: va al carril high
+	Escalated bool
+
+	// Parked cuenta las veces que la tarea se reprogramó porque su destino
+	// tenía el breaker abierto (el límite de concurrencia no cuenta)
+	Parked int
+
+	// Attempt reintentos encolados ya usados tras fallas de entrega
+	Attempt int
+
+	// NotBefore la tarea no se entrega antes de este momento (reintentos)
+	NotBefore time.Time
+}
+
+// key agrupa las tareas que deben entregarse en orden: mismo destino y misma orden.
+func (t WorkerTask) key() string {
+	return fmt.Sprintf("%s|%d", t.WebhookSuffix, t.Order.ID)
+}
+
+// delivery convierte la tarea en la entrega que entiende el sender
+func (t WorkerTask) delivery() webhook.Delivery {
+	return webhook.Delivery{
+		Order:         t.Order,
+		WebhookSuffix: t.WebhookSuffix,
+		CountrySuffix: t.CountrySuffix,
+		Format:        t.Format,
+		Options:       t.Options,
+		Version:       t.Version,
+		Event:         t.Event,
+	}
+}
+
+// BatchConfig agrupa tareas del mismo destino en un solo POST.
+// Con MaxSize <= 1 el batching está deshabilitado.
+type BatchConfig struct {
+	MaxSize int           // máximo de tareas por batch
+	Window  time.Duration // tiempo máximo esperando para completar un batch
+}
+
+func (c BatchConfig) enabled() bool {
+	return c.MaxSize > 1
+}
+
+// BatchConfigFromEnv lee WEBHOOK_BATCH_SIZE y WEBHOOK_BATCH_WINDOW (ej. "2s").
+func BatchConfigFromEnv() BatchConfig {
+	cfg := BatchConfig{MaxSize: 1, Window: 2 * time.Second}
+
+	if v := os.Getenv("WEBHOOK_BATCH_SIZE"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			cfg.MaxSize = n
+		} else {
+			slog.Warn("WEBHOOK_BATCH_SIZE inválido, batching deshabilitado", "value", v)
+		}
+	}
+	if v := os.Getenv("WEBHOOK_BATCH_WINDOW"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			cfg.Window = d
+		} else {
+			slog.Warn("WEBHOOK_BATCH_WINDOW inválido, usando default", "value", v, "default", cfg.Window)
+		}
+	}
+
+	return cfg
+}
+
+// ParkConfig controla cuándo se reintenta una tarea cuyo destino no estaba
+// disponible. La tarea se reprograma en vez de bloquear al worker.
+//
+// Un destino en su límite de concurrencia solo está ocupado: la tarea vuelve
+// después de Delay y no consume reprogramaciones. Con el breaker abierto la
+// espera sigue el retry schedule del pool (nunca menos que Delay) y la tarea
+// se descarta después de MaxParks reprogramaciones.
+type ParkConfig struct {
+	Delay    time.Duration // espera mínima antes de reencolar
+	MaxParks int           // reprogramaciones por breaker abierto antes de descartar la tarea
+}
+
+// defaultParkConfig con el schedule por defecto un destino caído tiene ~10h
+// para recuperarse.
+func defaultParkConfig() ParkConfig {
+	return ParkConfig{Delay: 30 * time.Second, MaxParks: 8}
+}
+
+// ParkConfigFromEnv lee WEBHOOK_PARK_DELAY (ej. "30s") y WEBHOOK_PARK_MAX.
+func ParkConfigFromEnv() ParkConfig {
+	cfg := defaultParkConfig()
+
+	if v := os.Getenv("WEBHOOK_PARK_DELAY"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			cfg.Delay = d
+		} else {
+			slog.Warn("WEBHOOK_PARK_DELAY inválido, usando default", "value", v, "default", cfg.Delay)
+		}
+	}
+	if v := os.Getenv("WEBHOOK_PARK_MAX"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.MaxParks = n
+		} else {
+			slog.Warn("WEBHOOK_PARK_MAX inválido, usando default", "value", v, "default", cfg.MaxParks)
+		}
+	}
+
+	return cfg
 }
 
 type WorkerPool struct {
-	jobs    chan WorkerTask
-	workers int
-	sender  *webhook.Sender
+	queue      *taskQueue
+	sender     *webhook.Sender
+	batch      BatchConfig
+	parking    ParkConfig
+	priorities PriorityConfig
+
+	// retrySchedule esperas entre reintentos encolados (1m, 5m, 30m, 2h, ...)
+	retrySchedule []time.Duration
+
+	// Tamaño dinámico: cada worker tiene su propio cancel para poder achicar
+	// el pool sin cortar entregas en curso
+	mu        sync.Mutex
+	ctx       context.Context
+	stops     []context.CancelFunc
+	nextID    int
+	config    PoolConfig
+	autoscale bool
+	busy      atomic.Int64
+	latency   latencyTracker
 }
 
 func NewWorkerPool(sender *webhook.Sender, workers int) *WorkerPool {
@@ -25,41 +161,401 @@ func NewWorkerPool(sender *webhook.Sender, workers int) *WorkerPool {
 	}
 
 	return &WorkerPool{
-		jobs:    make(chan WorkerTask, 10000),
-		workers: workers,
+		queue:   newTaskQueue(10000),
 		sender:  sender,
+		config:  PoolConfig{Size: workers, Min: 1, Max: workers, Interval: 15 * time.Second},
+		parking: defaultParkConfig(),
+
+		priorities:    DefaultPriorityConfig(),
+		retrySchedule: DefaultRetrySchedule,
 	}
 }
 
+// WithBatching habilita el envío agrupado por destino.
+func (wp *WorkerPool) WithBatching(cfg BatchConfig) *WorkerPool {
+	wp.batch = cfg
+	return wp
+}
+
+// WithParking configura la reprogramación de tareas con destino no disponible.
+func (wp *WorkerPool) WithParking(cfg ParkConfig) *WorkerPool {
+	wp.parking = cfg
+	return wp
+}
+
+// WithDelayedLimits acota las tareas reprogramadas, en total y por destino.
+func (wp *WorkerPool) WithDelayedLimits(limits DelayedLimits) *WorkerPool {
+	wp.queue.setDelayedLimits(limits)
+	return wp
+}
+
+// WithPriorities configura el mapeo status → carril y los pesos de cada carril.
+func (wp *WorkerPool) WithPriorities(cfg PriorityConfig) *WorkerPool {
+	wp.priorities = cfg
+	wp.queue.setWeights(cfg.Weights)
+	return wp
+}
+
+// WithConfig aplica límites de tamaño y autoscaling. El tamaño inicial de
+// NewWorkerPool se reemplaza por cfg.Size.
+func (wp *WorkerPool) WithConfig(cfg PoolConfig) *WorkerPool {
+	wp.config = cfg
+	wp.autoscale = cfg.Autoscale
+	return wp
+}
+
+// WithRetrySchedule configura los reintentos encolados. En vez de dormir en el
+// worker, una entrega fallida vuelve a la cola con NotBefore = ahora + espera.
+func (wp *WorkerPool) WithRetrySchedule(schedule []time.Duration) *WorkerPool {
+	wp.retrySchedule = schedule
+	return wp
+}
+
 func (wp *WorkerPool) Start(ctx context.Context) {
-	for i := 0; i < wp.workers; i++ {
-		go wp.worker(ctx, i)
+	wp.mu.Lock()
+	wp.ctx = ctx
+	wp.mu.Unlock()
+
+	wp.Resize(wp.config.Size)
+	go wp.runScheduler(ctx)
+
+	if wp.config.Interval > 0 {
+		go wp.runAutoscaler(ctx)
+	}
+}
+
+// Resize ajusta la cantidad de workers dentro de [Min, Max] y retorna el
+// tamaño aplicado. Los workers que sobran terminan su entrega en curso antes
+// de salir.
+func (wp *WorkerPool) Resize(n int) int {
+	wp.mu.Lock()
+	defer wp.mu.Unlock()
+
+	n = clamp(n, wp.config.Min, wp.config.Max)
+	if wp.ctx == nil {
+		wp.config.Size = n
+		return n
+	}
+
+	for len(wp.stops) < n {
+		stopCtx, cancel := context.WithCancel(wp.ctx)
+		wp.stops = append(wp.stops, cancel)
+		go wp.worker(wp.ctx, stopCtx, wp.nextID)
+		wp.nextID++
+	}
+	for len(wp.stops) > n {
+		last := len(wp.stops) - 1
+		wp.stops[last]()
+		wp.stops = wp.stops[:last]
+	}
+
+	wp.config.Size = n
+	return n
+}
+
+// Size cantidad actual de workers.
+func (wp *WorkerPool) Size() int {
+	wp.mu.Lock()
+	defer wp.mu.Unlock()
+	return wp.config.Size
+}
+
+// SetAutoscale habilita o deshabilita el autoscaler en caliente.
+func (wp *WorkerPool) SetAutoscale(enabled bool) {
+	wp.mu.Lock()
+	defer wp.mu.Unlock()
+	wp.autoscale = enabled
+}
+
+// AutoscaleEnabled indica si el autoscaler está activo.
+func (wp *WorkerPool) AutoscaleEnabled() bool {
+	wp.mu.Lock()
+	defer wp.mu.Unlock()
+	return wp.autoscale
+}
+
+// Stats estado actual del pool.
+func (wp *WorkerPool) Stats() PoolStats {
+	wp.mu.Lock()
+	stats := PoolStats{
+		Workers:    wp.config.Size,
+		MinWorkers: wp.config.Min,
+		MaxWorkers: wp.config.Max,
+		Autoscale:  wp.autoscale,
 	}
+	wp.mu.Unlock()
+
+	stats.Busy = int(wp.busy.Load())
+	stats.QueueDepth = wp.queue.len()
+	stats.QueueWaiting = wp.queue.waitingLen()
+	stats.QueueScheduled = wp.queue.scheduledLen()
+	stats.AvgLatencyMs = wp.latency.value().Milliseconds()
+	return stats
 }
 
-func (wp *WorkerPool) Enqueue(order models.DropiOrder, suffix string) {
-	wp.jobs <- WorkerTask{Order: order, WebhookSuffix: suffix}
+// priorityOf carril de la tarea: high si fue escalada, si no según su status.
+func (wp *WorkerPool) priorityOf(task WorkerTask) Priority {
+	if task.Escalated {
+		return PriorityHigh
+	}
+	return wp.priorities.priorityFor(string(task.Order.CanonicalStatus()))
 }
 
-func (wp *WorkerPool) worker(ctx context.Context, id int) {
+// ErrQueueFull indica que la cola de webhooks está llena; el llamador debe
+// aplicar backpressure en vez de bloquearse.
+var ErrQueueFull = errors.New("worker queue is full")
+
+// TryEnqueue encola la tarea sin bloquear. Retorna ErrQueueFull si no hay espacio.
+// Las tareas de una misma orden hacia un mismo destino se entregan en el orden
+// en que se encolaron.
+func (wp *WorkerPool) TryEnqueue(task WorkerTask) error {
+	task.Priority = wp.priorityOf(task)
+	return wp.queue.push(task)
+}
+
+// EnqueueContext espera espacio en la cola hasta que el context expire.
+func (wp *WorkerPool) EnqueueContext(ctx context.Context, task WorkerTask) error {
+	task.Priority = wp.priorityOf(task)
+
+	for {
+		err := wp.queue.push(task)
+		if !errors.Is(err, ErrQueueFull) {
+			return err
+		}
+		if err := wp.queue.waitSpace(ctx); err != nil {
+			return fmt.Errorf("%w: %v", ErrQueueFull, err)
+		}
+	}
+}
+
+// QueueDepth tareas listas esperando un worker. No incluye las que esperan
+// su clave ni las reprogramadas: es la medida de backpressure y autoscaling.
+func (wp *WorkerPool) QueueDepth() int {
+	return wp.queue.len()
+}
+
+// QueueDepthByPriority tareas listas por carril (high, normal, low).
+func (wp *WorkerPool) QueueDepthByPriority() map[string]int {
+	return wp.queue.readyByPriority()
+}
+
+// WaitingDepth tareas esperando que termine la entrega anterior de su misma
+// orden y destino.
+func (wp *WorkerPool) WaitingDepth() int {
+	return wp.queue.waitingLen()
+}
+
+// ScheduledDepth tareas reprogramadas esperando su próximo intento.
+func (wp *WorkerPool) ScheduledDepth() int {
+	return wp.queue.scheduledLen()
+}
+
+// QueueCapacity máximo de tareas listas (y, por separado, esperando su clave).
+func (wp *WorkerPool) QueueCapacity() int {
+	return wp.queue.capacity
+}
+
+// worker toma tareas hasta que stop se cancele (resize o apagado). Las entregas
+// usan ctx para que un resize no corte un envío en curso.
+func (wp *WorkerPool) worker(ctx, stop context.Context, id int) {
 	slog.Info("worker iniciado", "id", id)
 
 	for {
-		select {
-		case <-ctx.Done():
-			slog.Warn("worker apagado", "id", id)
+		task, ok := wp.queue.pop(stop)
+		if !ok {
+			if ctx.Err() != nil {
+				slog.Warn("worker apagado", "id", id)
+			} else {
+				slog.Info("worker detenido por resize", "id", id)
+			}
 			return
+		}
+
+		if wp.sender == nil {
+			slog.Error("webhook sender nil")
+			wp.queue.done(task)
+			continue
+		}
+
+		wp.busy.Add(1)
+		if wp.batch.enabled() {
+			wp.processBatch(ctx, wp.collect(ctx, task))
+		} else {
+			wp.process(ctx, task)
+		}
+		wp.busy.Add(-1)
+	}
+}
 
-		case task := <-wp.jobs:
-			if wp.sender == nil {
-				slog.Error("webhook sender nil")
-				continue
+// process envía una tarea individual y libera su clave al terminar
+func (wp *WorkerPool) process(ctx context.Context, task WorkerTask) {
+	d := task.delivery()
+
+	start := time.Now()
+	err := wp.sender.SendWebhook(ctx, d)
+	wp.latency.observe(time.Since(start))
+
+	switch {
+	case err == nil:
+		slog.Info("webhook enviado", "order_id", d.Order.ID, "suffix", d.WebhookSuffix)
+	case errors.Is(err, webhook.ErrDestinationUnavailable):
+		wp.park(task, err)
+		return
+	case wp.sender.Retryable(err) && wp.reschedule(task, retry.RetryAfter(err)):
+		return
+	default:
+		slog.Error("error enviando webhook", "order_id", d.Order.ID, "suffix", d.WebhookSuffix, "attempt", task.Attempt, "error", err)
+	}
+
+	wp.queue.done(task)
+}
+
+// park reprograma la tarea para más tarde sin ocupar un worker mientras el
+// destino se recupera. La tarea conserva su clave, así las siguientes entregas
+// de la misma orden esperan detrás de ella. Si el destino solo estaba en su
+// límite de concurrencia la reprogramación no cuenta para MaxParks.
+func (wp *WorkerPool) park(task WorkerTask, cause error) {
+	delay := wp.parking.Delay
+
+	if !errors.Is(cause, webhook.ErrDestinationBusy) {
+		task.Parked++
+		if task.Parked > wp.parking.MaxParks {
+			slog.Error("webhook descartado: destino no disponible",
+				"order_id", task.Order.ID,
+				"suffix", task.WebhookSuffix,
+				"parked", task.Parked-1,
+			)
+			wp.queue.done(task)
+			return
+		}
+		delay = wp.parkDelay(task.Parked)
+	}
+
+	slog.Warn("destino no disponible, tarea reprogramada",
+		"order_id", task.Order.ID,
+		"suffix", task.WebhookSuffix,
+		"parked", task.Parked,
+		"delay", delay,
+		"error", cause,
+	)
+
+	task.NotBefore = time.Now().Add(delay)
+	wp.delay(task)
+}
+
+// parkDelay espera de la reprogramación n (1-based) por breaker abierto: el
+// retry schedule del pool, repitiendo el último valor, con Delay como mínimo.
+func (wp *WorkerPool) parkDelay(n int) time.Duration {
+	delay := wp.parking.Delay
+	if len(wp.retrySchedule) == 0 {
+		return delay
+	}
+
+	i := n - 1
+	if i >= len(wp.retrySchedule) {
+		i = len(wp.retrySchedule) - 1
+	}
+	if wp.retrySchedule[i] > delay {
+		delay = wp.retrySchedule[i]
+	}
+	return delay
+}
+
+// collect junta tareas a partir de first hasta completar MaxSize o hasta que
+// venza la ventana de tiempo. Cada clave aparece a lo sumo una vez en el
+// batch porque la cola no entrega otra tarea de una clave activa.
+func (wp *WorkerPool) collect(ctx context.Context, first WorkerTask) []WorkerTask {
+	tasks := []WorkerTask{first}
+
+	windowCtx, cancel := context.WithTimeout(ctx, wp.batch.Window)
+	defer cancel()
+
+	for len(tasks) < wp.batch.MaxSize {
+		task, ok := wp.queue.pop(windowCtx)
+		if !ok {
+			break
+		}
+		tasks = append(tasks, task)
+	}
+
+	return tasks
+}
+
+// processBatch agrupa las tareas por destino (manteniendo el orden de llegada)
+// y envía un POST por grupo. Si el batch falla, o el receptor rechaza items,
+// se reintentan como envíos individuales. Si el destino no está disponible el
+// grupo completo se reprograma.
+func (wp *WorkerPool) processBatch(ctx context.Context, tasks []WorkerTask) {
+	groups := make(map[string][]WorkerTask)
+	var keys []string
+
+	for _, task := range tasks {
+		d := task.delivery()
+		// Los pins del destino pueden cambiar el formato y con él el batching
+		if wp.sender != nil {
+			d = wp.sender.Resolve(d)
+		}
+		if !d.Batchable() {
+			wp.process(ctx, task)
+			continue
+		}
+
+		key := d.DestinationKey()
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], task)
+	}
+
+	for _, key := range keys {
+		group := groups[key]
+		if len(group) == 1 {
+			wp.process(ctx, group[0])
+			continue
+		}
+
+		deliveries := make([]webhook.Delivery, len(group))
+		for i, task := range group {
+			deliveries[i] = task.delivery()
+		}
+
+		start := time.Now()
+		rejected, err := wp.sender.SendBatch(ctx, deliveries)
+		wp.latency.observe(time.Since(start))
+
+		if errors.Is(err, webhook.ErrDestinationUnavailable) {
+			for _, task := range group {
+				wp.park(task, err)
+			}
+			continue
+		}
+
+		retrySingle := make(map[int64]bool, len(rejected))
+		if err != nil {
+			slog.Warn("batch fallido, reintentando individualmente",
+				"suffix", group[0].WebhookSuffix,
+				"batch_size", len(group),
+				"error", err,
+			)
+			for _, task := range group {
+				retrySingle[task.Order.ID] = true
 			}
+		} else {
+			slog.Info("batch enviado",
+				"suffix", group[0].WebhookSuffix,
+				"batch_size", len(group),
+				"items_rejected", len(rejected),
+			)
+			for _, d := range rejected {
+				retrySingle[d.Order.ID] = true
+			}
+		}
 
-			if err := wp.sender.SendWebhook(task.Order, task.WebhookSuffix); err != nil {
-				slog.Error("error enviando webhook", "order_id", task.Order.ID, "suffix", task.WebhookSuffix, "error", err)
+		for _, task := range group {
+			if retrySingle[task.Order.ID] {
+				wp.process(ctx, task)
 			} else {
-				slog.Info("webhook enviado", "order_id", task.Order.ID, "suffix", task.WebhookSuffix)
+				wp.queue.done(task)
 			}
 		}
 	}
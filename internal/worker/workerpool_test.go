@@ -0,0 +1,58 @@
+package worker
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/juancollazo-ch/dropi-order-status-service/internal/webhook"
+)
+
+func TestParkBusyDoesNotCountTowardMaxParks(t *testing.T) {
+	wp := NewWorkerPool(nil, 1).WithParking(ParkConfig{Delay: time.Second, MaxParks: 1})
+
+	task := WorkerTask{WebhookSuffix: "client/hook"}
+	task.Order.ID = 1
+
+	for i := 0; i < 5; i++ {
+		wp.park(task, webhook.ErrDestinationBusy)
+	}
+	if got := wp.ScheduledDepth(); got != 5 {
+		t.Fatalf("busy parks should all be scheduled, got %d", got)
+	}
+	for _, parked := range wp.queue.delayed {
+		if parked.Parked != 0 {
+			t.Fatalf("busy park counted toward MaxParks: %d", parked.Parked)
+		}
+	}
+}
+
+func TestParkDropsAfterMaxParks(t *testing.T) {
+	wp := NewWorkerPool(nil, 1).WithParking(ParkConfig{Delay: time.Second, MaxParks: 2})
+	cause := fmt.Errorf("%w: circuit breaker is open", webhook.ErrDestinationUnavailable)
+
+	task := WorkerTask{WebhookSuffix: "client/hook", Parked: 2}
+	wp.park(task, cause)
+
+	if got := wp.ScheduledDepth(); got != 0 {
+		t.Fatalf("task over MaxParks should be dropped, got %d scheduled", got)
+	}
+}
+
+func TestParkDelayFollowsRetrySchedule(t *testing.T) {
+	wp := NewWorkerPool(nil, 1).
+		WithParking(ParkConfig{Delay: 30 * time.Second, MaxParks: 10}).
+		WithRetrySchedule([]time.Duration{10 * time.Second, time.Minute, 5 * time.Minute})
+
+	want := []time.Duration{30 * time.Second, time.Minute, 5 * time.Minute, 5 * time.Minute}
+	for i, w := range want {
+		if got := wp.parkDelay(i + 1); got != w {
+			t.Errorf("park %d: delay = %v, want %v", i+1, got, w)
+		}
+	}
+
+	wp.WithRetrySchedule(nil)
+	if got := wp.parkDelay(3); got != 30*time.Second {
+		t.Errorf("without schedule delay = %v, want Delay", got)
+	}
+}